@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/ebuckley/bff/pkg/bff"
@@ -13,14 +19,52 @@ import (
 
 func main() {
 	slog.SetLogLoggerLevel(slog.LevelDebug)
-	app := bff.New()
-	err := app.RegisterAction("upload a file", func(ctx context.Context, io *bff.Io) error {
-		f, err := io.Input.File("Upload a file")
+	// checkpoint running actions so they pick up where they left off when the server restarts
+	store, err := bff.NewFileStore(filepath.Join(os.TempDir(), "bff-checkpoints"))
+	if err != nil {
+		panic(err)
+	}
+	// keep a record of every run, so there is an answer to who did what
+	runs, err := bff.NewFileRunStore(filepath.Join(os.TempDir(), "bff-runs.jsonl"))
+	if err != nil {
+		panic(err)
+	}
+	// admins can look through everybody's runs, everyone else only sees their own
+	opts := []bff.Option{bff.WithStore(store), bff.WithRunStore(runs), bff.WithRunPolicy(bff.RolesRunPolicy("admin"))}
+	if os.Getenv("BFF_HTPASSWD") == "" {
+		// nobody signs in when running locally, so let them run everything
+		opts = append(opts, bff.WithPolicy(func(ctx context.Context, user *bff.User, action *bff.Action) bool {
+			return user == nil || bff.RolePolicy(ctx, user, action)
+		}))
+	}
+	app := bff.New(opts...)
+	err = app.RegisterAction("upload a file", func(ctx context.Context, io *bff.Io) error {
+		files, err := io.Input.File("Upload a text file", bff.WithAccept("text/*,.csv,.md"), bff.WithMaxFileSize(1<<20))
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			io.Display.Heading("No file was uploaded", 3)
+			return nil
+		}
+		f, err := files[0].Open()
 		if err != nil {
 			return err
 		}
+		defer f.Close()
+		lines := 0
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines++
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
 		io.Display.Metadata([]bff.MetadataItem{
-			{Label: "File Name", Value: f[0]},
+			{Label: "File Name", Value: files[0].Name},
+			{Label: "Type", Value: files[0].Type},
+			{Label: "Size", Value: fmt.Sprintf("%d bytes", files[0].Size)},
+			{Label: "Lines", Value: fmt.Sprint(lines)},
 		})
 		return nil
 	}, bff.WithSlug("upload_file"))
@@ -34,12 +78,12 @@ func main() {
 			return err
 		}
 
-		age, err := io.Input.Slider("Select your age", 18, 100)
+		age, err := io.Input.Slider("Select your age", 18, 100, bff.WithStep(1))
 		if err != nil {
 			return err
 		}
 
-		birthdate, err := io.Input.Date("Enter your birthdate")
+		birthdate, err := io.Input.Date("Enter your birthdate", bff.WithMaxDate(time.Now()))
 		if err != nil {
 			return err
 		}
@@ -59,7 +103,7 @@ func main() {
 			return err
 		}
 
-		avatar, err := io.Input.File("Upload your avatar")
+		avatar, err := io.Input.File("Upload your avatar", bff.WithAccept("image/*"))
 		if err != nil {
 			return err
 		}
@@ -72,7 +116,7 @@ func main() {
 			{Label: "Bio", Value: bio},
 			{Label: "Website", Value: website},
 			{Label: "Available Time", Value: fmt.Sprint(availableTime)},
-			{Label: "Avatar", Value: fmt.Sprint(avatar)},
+			{Label: "Avatar", Value: fileNames(avatar)},
 		}, bff.WithMetadataLayout("table"))
 
 		return nil
@@ -83,7 +127,11 @@ func main() {
 
 	err = app.RegisterAction("hello", func(ctx context.Context, io *bff.Io) error {
 
-		io.Display.Heading("Hello World!", 1)
+		greeting := "Hello World!"
+		if user, ok := bff.UserFromContext(ctx); ok && user.Name != "" {
+			greeting = "Hello " + user.Name + "!"
+		}
+		io.Display.Heading(greeting, 1)
 		io.Display.Image("https://media.giphy.com/media/26ybw6AltpBRmyS76/giphy.gif", "gopher", "medium")
 
 		io.Display.Link("Visit Go's website", "https://golang.org", bff.WithLinkType("primary"))
@@ -131,12 +179,47 @@ You can use markdown to write things, whatever you want -- it just works!
 		panic(err)
 	}
 
-	err = app.RegisterAction("launch nukes", launchNukes, bff.WithSlug("nuke"))
+	err = app.RegisterAction("list customers", listCustomers, bff.WithSlug("customers"))
+	if err != nil {
+		panic(err)
+	}
+
+	err = app.RegisterActionWithResult("refund charges", refundCharges, bff.WithSlug("refund"))
+	if err != nil {
+		panic(err)
+	}
+
+	err = app.RegisterAction("find customer", findCustomer, bff.WithSlug("find_customer"))
+	if err != nil {
+		panic(err)
+	}
+
+	err = app.RegisterAction("add customer", addCustomer, bff.WithSlug("add_customer"))
 	if err != nil {
 		panic(err)
 	}
 
-	s := server.NewServer(app)
+	err = app.RegisterAction("launch nukes", launchNukes, bff.WithSlug("nuke"), bff.WithRoles("admin"),
+		bff.WithActionTimeout(10*time.Minute))
+	if err != nil {
+		panic(err)
+	}
+
+	var serverOpts []server.Serveropts
+	// BFF_HTPASSWD makes everyone sign in with a user and password from a htpasswd file, the users in BFF_ADMINS
+	// (comma separated) can launch nukes
+	if path := os.Getenv("BFF_HTPASSWD"); path != "" {
+		auth, err := server.NewHtpasswdAuthenticator(path)
+		if err != nil {
+			panic(err)
+		}
+		auth.Roles = make(map[string][]string)
+		for _, admin := range strings.Split(os.Getenv("BFF_ADMINS"), ",") {
+			auth.Roles[strings.TrimSpace(admin)] = []string{"admin"}
+		}
+		serverOpts = append(serverOpts, server.WithAuthenticator(auth))
+	}
+	s := server.NewServer(app, serverOpts...)
 	slog.Info("starting server on :8181")
 
 	err = http.ListenAndServe(":8181", logger(s))
@@ -153,6 +236,14 @@ func logger(h http.Handler) http.Handler {
 	})
 }
 
+// logNotifier "sends" messages to the log, handy for trying things out locally
+type logNotifier struct{}
+
+func (logNotifier) Notify(ctx context.Context, recipient string, message string) error {
+	slog.Info("notification", "to", recipient, "message", message)
+	return nil
+}
+
 func launchNukes(ctx context.Context, io *bff.Io) error {
 	io.Display.Heading("Read to launch some nukes?!", 1)
 	io.Display.Markdown(`
@@ -160,7 +251,11 @@ func launchNukes(ctx context.Context, io *bff.Io) error {
 
 In this example you will see a few cool things like Yes/No booleans, text inputs markdown outputs and the way that this all happens in realtime.
 `)
-	confirm, err := io.Input.Boolean("Are you sure you want to launch the nuke?")
+	confirm, err := io.Input.Confirm("Are you sure you want to launch the nuke?",
+		bff.WithConfirmHelpText("There is no undo button for a nuclear launch"),
+		bff.WithDanger(),
+		bff.WithConfirmLabels("Launch", "Abort"),
+	)
 	if err != nil {
 		return err
 	}
@@ -168,23 +263,40 @@ In this example you will see a few cool things like Yes/No booleans, text inputs
 		io.Display.Heading("Nuke launch aborted, you are a good person", 1)
 		return nil
 	}
+	// the code is only logged here, a real tool would email or message it to the person running the action
+	err = io.Input.ConfirmIdentity("Confirm it's you before we go any further", bff.NewCodeVerifier(logNotifier{}, "the launch officer"))
+	if err != nil {
+		return err
+	}
 	io.Display.Heading("Great! Let's plan a nuke launch!", 1)
-	city, err := io.Input.Text("What city would you like to destroy?")
+	city, err := io.Input.Text("What city would you like to destroy?", bff.WithRequired(true), bff.WithValidator(func(city string) error {
+		if strings.EqualFold(city, "springfield") {
+			return errors.New("Springfield is under our protection")
+		}
+		return nil
+	}))
 	if err != nil {
 		return err
 	}
-	killCivvies, err := io.Input.Boolean("spare civilians?")
+	killCivvies, err := io.Input.Boolean("spare civilians?", bff.WithTimeout(time.Minute), bff.WithDefault(true))
 	if err != nil {
 		return err
 	}
 
-	countDown, err := io.Input.Number("How many seconds until launch?")
+	countDown, err := io.Input.Number("How many seconds until launch?", bff.WithMin(1), bff.WithMax(60))
 	if err != nil {
 		return err
 	}
+	io.Loading.Start(fmt.Sprintf("Launching it in %ds", countDown), countDown)
 	for i := countDown; i > 0; i-- {
-		io.Display.Heading(fmt.Sprintf("Launching it in %ds", i), 1)
-		time.Sleep(1 * time.Second)
+		// cancelling the run aborts the launch
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+		io.Loading.CompleteOne()
+		io.Loading.Update(fmt.Sprintf("Launching it in %ds", i-1))
 	}
 
 	io.Display.Heading("Great job destroying "+city, 1)
@@ -201,3 +313,134 @@ In this example you will see a few cool things like Yes/No booleans, text inputs
 
 	return nil
 }
+
+type customer struct {
+	ID        int       `bff:"label=Customer ID"`
+	Name      string    `bff:"label=Name"`
+	Plan      string    `bff:"label=Plan"`
+	Spend     float64   `bff:"label=Lifetime spend"`
+	CreatedAt time.Time `bff:"label=Signed up"`
+}
+
+func makeCustomers() []customer {
+	plans := []string{"free", "pro", "enterprise"}
+	customers := make([]customer, 0, 10000)
+	for i := range 10000 {
+		customers = append(customers, customer{
+			ID:        i + 1,
+			Name:      fmt.Sprintf("Customer %d", i+1),
+			Plan:      plans[i%len(plans)],
+			Spend:     float64((i * 7919) % 100000),
+			CreatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Hour),
+		})
+	}
+	return customers
+}
+
+func listCustomers(ctx context.Context, io *bff.Io) error {
+	return io.Display.Table("Customers", makeCustomers(),
+		bff.WithTableFormatter("Spend", func(v any) string { return fmt.Sprintf("$%.2f", v) }),
+		bff.WithTableFormatter("CreatedAt", func(v any) string { return v.(time.Time).Format("2006-01-02") }),
+	)
+}
+
+type charge struct {
+	ID     string  `bff:"label=Charge"`
+	Amount float64 `bff:"label=Amount"`
+	Note   string  `bff:"label=Note"`
+}
+
+// refundCharges returns the charges it refunded, they are shown at the end and kept in the run log
+func refundCharges(ctx context.Context, io *bff.Io) (any, error) {
+	email, err := io.Input.Email("Email of the customer to refund")
+	if err != nil {
+		return nil, err
+	}
+	charges := []charge{
+		{ID: "ch_1", Amount: 12.50, Note: "monthly plan"},
+		{ID: "ch_2", Amount: 99, Note: "annual upgrade"},
+		{ID: "ch_3", Amount: 4.20, Note: "add-on"},
+	}
+	refunds, err := bff.SelectTable(io, "Select one or more charges to refund", charges, bff.WithSelectionLimits(1, 0))
+	if err != nil {
+		return nil, err
+	}
+	total := 0.0
+	for _, c := range refunds {
+		total += c.Amount
+	}
+	io.Display.Heading(fmt.Sprintf("Refunded %d charges totalling $%.2f to %s", len(refunds), total, email), 2)
+	return refunds, nil
+}
+
+func findCustomer(ctx context.Context, io *bff.Io) error {
+	customers := makeCustomers()
+	found, err := bff.Search(io, "Search for a customer by name",
+		func(ctx context.Context, query string) ([]customer, error) {
+			var matches []customer
+			for _, c := range customers {
+				if strings.Contains(strings.ToLower(c.Name), strings.ToLower(query)) {
+					matches = append(matches, c)
+				}
+				if len(matches) == 10 {
+					break
+				}
+			}
+			return matches, nil
+		},
+		func(c customer) bff.SearchResult {
+			return bff.SearchResult{Label: c.Name, Description: c.Plan + " plan"}
+		},
+	)
+	if err != nil {
+		return err
+	}
+	plans := []string{"free", "pro", "enterprise"}
+	values, err := io.Group(
+		bff.HeadingDisplay{Text: "Update " + found.Name, Level: 2},
+		bff.NewTextInput("Name", bff.WithRequired(true), bff.WithDefault(found.Name)),
+		bff.NewSelectSingleInput("Which plan should they be on?", []bff.SelectChoice{
+			{Label: "Free"},
+			{Label: "Pro"},
+			{Label: "Enterprise", Description: "Includes support"},
+		}, bff.WithDefault(slices.Index(plans, found.Plan))),
+		bff.NewBooleanInput("Reset password?", bff.WithHelpText("This will log them out of all current sessions")),
+	)
+	if err != nil {
+		return err
+	}
+	name, plan, reset := values[1].(string), plans[values[2].(int)], values[3].(bool)
+	io.Display.Metadata([]bff.MetadataItem{
+		{Label: "Name", Value: name},
+		{Label: "Plan", Value: plan},
+		{Label: "Password reset", Value: fmt.Sprint(reset)},
+		{Label: "Lifetime spend", Value: fmt.Sprintf("$%.2f", found.Spend)},
+	}, bff.WithMetadataLayout("card"))
+	return nil
+}
+
+type newCustomer struct {
+	Name   string    `bff:"label=Name,required,maxLength=100"`
+	Email  string    `bff:"label=Email,required,input=email"`
+	Plan   string    `bff:"label=Plan,options=free|pro|enterprise"`
+	Seats  int       `bff:"label=Seats,min=1,max=500,help=How many people will sign in"`
+	Starts time.Time `bff:"label=Start date"`
+}
+
+// addCustomer asks for everything at once with a form made from the newCustomer struct
+func addCustomer(ctx context.Context, io *bff.Io) error {
+	c, err := bff.Form[newCustomer](io, "Add a customer")
+	if err != nil {
+		return err
+	}
+	io.Display.Object("Added", c)
+	return nil
+}
+
+func fileNames(files []bff.UploadedFile) string {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.Name
+	}
+	return strings.Join(names, ", ")
+}
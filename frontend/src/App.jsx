@@ -3,7 +3,7 @@ import React, {useEffect, useState} from "react";
 import {marked} from "marked";
 import SyntaxHighlighter from 'react-syntax-highlighter';
 import {atomDark} from "react-syntax-highlighter/src/styles/prism/index.js";
-import {Commitable} from "./util/components.jsx";
+import {CardContext, Commitable, RunContext, useSendInput} from "./util/components.jsx";
 import {FileInput} from "./inputs/FileInput.jsx";
 import {EmailInput} from "./inputs/EmailInput.jsx";
 import {DateInput} from "./inputs/DateInput.jsx";
@@ -11,12 +11,22 @@ import {RichTextInput} from "./inputs/RichTextInput.jsx";
 import {URLInput} from "./inputs/URLInput.jsx";
 import {TimeInput} from "./inputs/TimeInput.jsx";
 import {SliderInput} from "./inputs/SliderInput.jsx";
-import {backend, useAppState, actionName} from "./util/state.js";
+import {backend, useAppState, actionName, alongside, newRun, loadSessionId, saveSessionId, csrfToken, protocolVersion} from "./util/state.js";
 import {TextAreaInput} from "./inputs/TextAreaInput.jsx";
 import {Input} from "./ui/Input.jsx";
 import {Switch} from "./ui/Switch.jsx";
 import {Label} from "./ui/Label.jsx";
 import {Card, CardContent, CardHeader} from "./ui/Card.jsx";
+import {Button} from "./ui/Button.jsx";
+import {TableDisplay} from "./displays/TableDisplay.jsx";
+import {SelectTableInput} from "./inputs/SelectTableInput.jsx";
+import {SearchInput} from "./inputs/SearchInput.jsx";
+import {SelectInput} from "./inputs/SelectInput.jsx";
+import {ConfirmInput} from "./inputs/ConfirmInput.jsx";
+import {ConfirmIdentityInput} from "./inputs/ConfirmIdentityInput.jsx";
+import {LoadingDisplay} from "./displays/LoadingDisplay.jsx";
+import {ObjectDisplay} from "./displays/ObjectDisplay.jsx";
+import {GroupDisplay} from "./displays/GroupDisplay.jsx";
 
 
 console.log('Backend URL:', backend)
@@ -27,10 +37,10 @@ const displayable = {
         const textStyle = `text-2xl font-bold`
         return <Tag className={textStyle}>{text}</Tag>
     },
-    'numberInput': ({label, helpText, placeholder, required}) => {
-        const {sendInput} = useAppState();
+    'numberInput': ({label, helpText, placeholder, required, defaultValue}) => {
+        const sendInput = useSendInput();
 
-        const [value, setValue] = useState('')
+        const [value, setValue] = useState(defaultValue ?? '')
 
         const commitSend = () => {
             sendInput(value)
@@ -46,10 +56,10 @@ const displayable = {
             </>}/>
         )
     },
-    'textInput': ({label, helpText, placeholder, required}) => {
-        const {sendInput} = useAppState();
+    'textInput': ({label, helpText, placeholder, required, defaultValue}) => {
+        const sendInput = useSendInput();
 
-        const [value, setValue] = useState('')
+        const [value, setValue] = useState(defaultValue ?? '')
 
         const commitSend = () => {
             sendInput(value)
@@ -65,16 +75,16 @@ const displayable = {
             </>}/>
         )
     },
-    booleanInput: ({label, helpText, placeholder, required}) => {
-        const {sendInput} = useAppState();
-        const [value, setValue] = useState(false)
+    booleanInput: ({label, helpText, placeholder, required, defaultValue}) => {
+        const sendInput = useSendInput();
+        const [value, setValue] = useState(defaultValue ?? false)
         return (
             <Commitable onCommit={() => {
                 sendInput(value)
                 return true;
             }} content={<div className={"flex flex-col gap-2"}>
                 <Label>{label}</Label>
-                <Switch value={value} onClick={() => setValue(true)}/>
+                <Switch checked={value} onCheckedChange={setValue}/>
                 <p className={"text-sm"}>{helpText}</p>
             </div>}/>)
     },
@@ -153,16 +163,32 @@ const displayable = {
     'timeInput': TimeInput,
     'fileInput': FileInput,
     'textAreaInput': TextAreaInput,
+    'table': TableDisplay,
+    'object': ObjectDisplay,
+    'selectTableInput': SelectTableInput,
+    'searchInput': SearchInput,
+    'selectSingleInput': (props) => <SelectInput {...props} multiple={false}/>,
+    'selectMultipleInput': (props) => <SelectInput {...props} multiple={true}/>,
+    'confirmInput': ConfirmInput,
+    'confirmIdentityInput': ConfirmIdentityInput,
+    'group': (props) => <GroupDisplay {...props} displayable={displayable}/>,
 }
 
+// set when the app is torn down, so a closed socket is not reconnected
+let closing = false
+
 function setupWebSocket() {
-    const socket = new WebSocket(`${window.location.protocol === 'https:' ? 'wss' : 'ws'}://${backend}`);
+    const sessionId = loadSessionId()
+    const params = new URLSearchParams({csrf: csrfToken, version: protocolVersion})
+    if (sessionId) {
+        params.set('session', sessionId)
+    }
+    const query = `?${params}`
+    const socket = new WebSocket(`${window.location.protocol === 'https:' ? 'wss' : 'ws'}://${backend}${query}`);
 
     socket.onopen = () => {
         console.log('WebSocket connection established');
-        socket.send('{"type": "ping"}');
-        const s = useAppState.getState()
-        s.startAction(actionName)
+        socket.send(JSON.stringify({type: 'ping', version: protocolVersion}));
     };
 
     socket.onmessage = (event) => {
@@ -174,16 +200,77 @@ function setupWebSocket() {
             return;
         }
         const {type, data} = d;
+        if (type === 'session') {
+            saveSessionId(data.id)
+            useAppState.setState((state) => ({...state, version: data.version ?? 0}))
+            if (data.resumed) {
+                // the session replays its history next, start from a clean slate
+                useAppState.setState((state) => ({...state, runs: {}, runOrder: []}))
+            } else {
+                const {startAction} = useAppState.getState()
+                startAction(actionName)
+                alongside.forEach(startAction)
+            }
+            return
+        }
         // pages/actions just yeet their state into the store directly
         if (type === 'pages' || type === 'actions') {
             useAppState.setState((state) => ({...state, [type]: data}))
         }
+        if ((type === 'error' || type === 'rejected') && !d.run) {
+            // the connection went wrong rather than a run, it is shown above them all
+            useAppState.setState((state) => ({...state, history: [...state.history, d]}))
+            return
+        }
+        // everything else is about a run, a replayed history can mention runs this page didn't start
+        const run = d.run ?? useAppState.getState().runOrder.at(-1)
+        const updateRun = (update) => useAppState.setState((state) => {
+            const current = state.runs[run] ?? newRun(null)
+            return {
+                ...state,
+                runs: {...state.runs, [run]: {...current, ...update(current)}},
+                runOrder: state.runOrder.includes(run) ? state.runOrder : [...state.runOrder, run],
+            }
+        })
+        // answers and errors are about the last prompt of the run
+        const updateLastCard = (update) => updateRun(({cards}) => ({
+            cards: cards.map((card, i) => i === cards.length - 1 ? {...card, ...update} : card),
+        }))
+        if (type === 'queryResult') {
+            updateRun(({queryResults}) => ({queryResults: {...queryResults, [data.id]: data}}))
+            return
+        }
+        if (type === 'loading') {
+            // progress replaces the last progress rather than piling up, it isn't kept in the history either
+            updateRun(() => ({loading: data}))
+            return
+        }
+        if (type === 'input') {
+            // replayed answer to the last prompt
+            updateLastCard({answered: true})
+        }
+        if (type === 'validationError') {
+            // the server turned down the answer to the last prompt, it stays open to be fixed. A group says which of its
+            // inputs were wrong by their position.
+            updateLastCard({answered: false, error: typeof data === 'string' ? {message: data} : {fields: data}})
+        }
+        if (type === 'rejected') {
+            // the server didn't take the answer, I.E it was for a prompt that had already timed out
+            updateLastCard({answered: false, error: {message: data}})
+        }
+        if (type === 'inputTimeout') {
+            // the server stopped waiting for an answer to the last prompt
+            updateLastCard({expired: true})
+        }
         if (type in displayable) {
-            useAppState.setState((state) => ({...state, cards: [...state.cards, {type, data}]}))
+            // the next thing to show means whatever was loading is done
+            updateRun(({cards}) => ({cards: [...cards, {type, data, id: d.id}], loading: null}))
+        }
+        if (type === 'done' || type === 'cancelled') {
+            updateRun(() => ({status: type, loading: null}))
         }
-        if (type === 'done') {
-            // todo send something into state for rendering that this is done ta-da
-            useAppState.setState((state) => ({...state, currentAction: null}))
+        if (type === 'error') {
+            updateRun(() => ({status: type, error: data, loading: null}))
         }
 
         // also append the message to the global history of messages
@@ -192,7 +279,15 @@ function setupWebSocket() {
 
     socket.onclose = () => {
         console.log('WebSocket connection closed');
-        // todo start retry connection?
+        if (closing || useAppState.getState().socket !== socket) {
+            return
+        }
+        // the session keeps running on the server, reconnect and carry on
+        setTimeout(() => {
+            if (!closing) {
+                setupWebSocket()
+            }
+        }, 1000)
     };
 
     socket.onerror = (error) => {
@@ -203,30 +298,64 @@ function setupWebSocket() {
     return socket
 }
 
+// Run shows the cards of one run of an action, what its inputs send is for that run
+function Run({id}) {
+    const {runs, cancelAction} = useAppState()
+    const run = runs[id]
+    return (
+        <RunContext.Provider value={id}>
+            <div className={"flex flex-col gap-2 pb-6 min-w-0"}>
+                {run.cards.map((card, i) => {
+                    const Displayable = displayable[card.type]
+                    return (
+                        <CardContext.Provider key={i} value={{answered: !!card.answered, error: card.error ?? null, expired: !!card.expired}}>
+                            <Displayable {...card.data} />
+                        </CardContext.Provider>
+                    )
+                })}
+                {run.loading && <LoadingDisplay {...run.loading}/>}
+                {run.status === 'error' && (
+                    <div className="py-6 px-3 bg-red-400 color-red-900 rounded border-2 border-red-900">
+                        <span className={"font-bold pr-1"}> Error </span> {run.error}
+                    </div>
+                )}
+                {run.status === 'cancelled' && <div className="py-3 px-3 bg-gray-100 rounded text-gray-700">Cancelled</div>}
+                {!run.status && (
+                    <div className="flex justify-end">
+                        <Button variant="outline" onClick={() => cancelAction(id)}>Cancel</Button>
+                    </div>
+                )}
+            </div>
+        </RunContext.Provider>
+    )
+}
+
 function App() {
     const app = useAppState()
-    const errors = app.history.filter((msg) => msg.type === 'error').map((msg,i) => (
+    const errors = app.history.filter((msg) => (msg.type === 'error' || msg.type === 'rejected') && !msg.run).map((msg,i) => (
     <div key={i} className="py-6 px-3 bg-red-400 color-red-900 rounded border-2 border-red-900">
         <span className={"font-bold pr-1"}> Error </span> {msg.data}
     </div>))
     useEffect(() => {
-        const socket = setupWebSocket()
+        closing = false
+        setupWebSocket()
         return () => {
+            closing = true
+            const {socket} = useAppState.getState()
             if (socket) {
                 socket.close()
             }
         }
     }, [])
+    // several runs are shown side by side
+    const wide = app.runOrder.length > 1
     return (
-        <div className={"py-6 mx-auto max-w-2xl"}>
+        <div className={`py-6 mx-auto ${wide ? "max-w-6xl px-3" : "max-w-2xl"}`}>
             <div className="flex flex-col gap-2 pb-3">
                 {errors}
             </div>
-            <div className={"flex flex-col gap-2 pb-6"}>
-                {app.cards.map((card, i) => {
-                    const Displayable = displayable[card.type]
-                    return <Displayable key={i} {...card.data} />
-                })}
+            <div className={wide ? "grid grid-flow-col auto-cols-fr gap-6" : ""}>
+                {app.runOrder.map((id) => <Run key={id} id={id}/>)}
             </div>
 
             <details className="group border border-gray-200 rounded-lg shadow-sm">
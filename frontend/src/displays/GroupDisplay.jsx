@@ -0,0 +1,57 @@
+import React, {useContext, useRef} from "react";
+import {CardContext, Commitable, GroupContext, useRun} from "../util/components.jsx";
+
+const isInput = (type) => type.endsWith('Input')
+
+// GroupDisplay shows its elements in one card, the inputs in it are submitted together as a list of their answers.
+// displayable is passed in by the app, it is how each element is shown on its own.
+export const GroupDisplay = ({elements, displayable}) => {
+    const {sendInput} = useRun();
+    const {answered, error, expired} = useContext(CardContext);
+    // what each input would commit and send, by its position in the group
+    const commits = useRef({});
+    const values = useRef({});
+    const inputs = elements.map((_, i) => i).filter((i) => isInput(elements[i].type));
+
+    const content = (
+        <div className={"flex flex-col gap-6"}>
+            {elements.map(({type, data}, i) => {
+                const Displayable = displayable[type];
+                if (!Displayable) {
+                    return null;
+                }
+                const group = {
+                    setCommit: (commit) => {
+                        commits.current[i] = commit;
+                    },
+                    send: (value) => {
+                        values.current[i] = value;
+                    },
+                };
+                // the server says which of the inputs were wrong by their position
+                const fieldError = error?.fields?.[i];
+                return (
+                    <CardContext.Provider key={i} value={{answered, expired, error: fieldError ? {message: fieldError} : null}}>
+                        <GroupContext.Provider value={group}>
+                            <Displayable {...data}/>
+                        </GroupContext.Provider>
+                    </CardContext.Provider>
+                );
+            })}
+        </div>
+    );
+    if (inputs.length === 0) {
+        return content;
+    }
+
+    const handleCommit = async () => {
+        for (const i of inputs) {
+            if (!commits.current[i] || !(await commits.current[i]())) {
+                return false;
+            }
+        }
+        sendInput(inputs.map((i) => values.current[i]));
+        return true;
+    };
+    return <Commitable onCommit={handleCommit} content={content}/>;
+};
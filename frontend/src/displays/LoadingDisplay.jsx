@@ -0,0 +1,24 @@
+import React from "react";
+import {Card, CardContent, CardHeader} from "../ui/Card.jsx";
+
+// LoadingDisplay shows how a long running action is getting on, a progress bar when the number of items is known and
+// a spinner otherwise
+export const LoadingDisplay = ({title, itemsInQueue, itemsCompleted}) => (
+    <Card>
+        <CardHeader></CardHeader>
+        <CardContent className={"flex flex-col gap-2"}>
+            <div className={"flex items-center gap-3"}>
+                {!itemsInQueue && (
+                    <span className={"inline-block w-4 h-4 rounded-full border-2 border-gray-300 border-t-gray-700 animate-spin"}/>
+                )}
+                <span className={"font-medium"}>{title}</span>
+            </div>
+            {itemsInQueue > 0 && (
+                <>
+                    <progress className={"w-full"} value={itemsCompleted} max={itemsInQueue}/>
+                    <p className={"text-sm text-gray-500"}>{itemsCompleted} of {itemsInQueue} completed</p>
+                </>
+            )}
+        </CardContent>
+    </Card>
+)
@@ -0,0 +1,41 @@
+import React from "react";
+import {Card, CardContent, CardHeader} from "../ui/Card.jsx";
+
+// ObjectValue renders nested data, objects as label value pairs and arrays as lists
+const ObjectValue = ({value}) => {
+    if (Array.isArray(value)) {
+        if (value.length === 0) {
+            return <span className={"text-gray-500"}>empty</span>
+        }
+        return (
+            <ol className={"list-decimal pl-6"}>
+                {value.map((v, i) => <li key={i}><ObjectValue value={v}/></li>)}
+            </ol>
+        )
+    }
+    if (value !== null && typeof value === 'object') {
+        return (
+            <dl className={"grid grid-cols-[auto_1fr] gap-x-4 gap-y-1"}>
+                {Object.entries(value).map(([key, v]) => (
+                    <React.Fragment key={key}>
+                        <dt className={"font-bold"}>{key}</dt>
+                        <dd><ObjectValue value={v}/></dd>
+                    </React.Fragment>
+                ))}
+            </dl>
+        )
+    }
+    if (value === null || value === undefined) {
+        return <span className={"text-gray-500"}>none</span>
+    }
+    return <span>{String(value)}</span>
+}
+
+export const ObjectDisplay = ({label, value}) => (
+    <Card>
+        <CardHeader>{label && <h3 className={"font-bold"}>{label}</h3>}</CardHeader>
+        <CardContent>
+            <ObjectValue value={value}/>
+        </CardContent>
+    </Card>
+)
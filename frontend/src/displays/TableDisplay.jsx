@@ -0,0 +1,85 @@
+import React, {useEffect, useState} from 'react';
+import {useRun} from "../util/components.jsx";
+import {Card, CardContent, CardHeader} from "../ui/Card.jsx";
+import {Button} from "../ui/Button.jsx";
+
+// useTablePage asks the backend for pages of the table with the given id, the first page comes with the table itself
+export const useTablePage = (id, firstPage) => {
+    const {sendQuery, queryResults} = useRun();
+    const [query, setQuery] = useState({page: 0, sortBy: '', desc: false});
+    const result = queryResults[id];
+
+    useEffect(() => {
+        if (query.page === 0 && !query.sortBy && !result) {
+            // the first page is already here
+            return
+        }
+        sendQuery(id, query)
+    }, [id, query.page, query.sortBy, query.desc]);
+
+    const page = result?.result || firstPage
+    const sortBy = (key) => setQuery((q) => ({
+        page: 0,
+        sortBy: key,
+        desc: q.sortBy === key ? !q.desc : false,
+    }))
+    const goTo = (n) => setQuery((q) => ({...q, page: n}))
+    return {page, query, sortBy, goTo, error: result?.error}
+}
+
+export const TablePager = ({page, goTo}) => {
+    const pages = Math.max(1, Math.ceil(page.total / page.pageSize))
+    return (
+        <div className="flex items-center justify-between pt-4 text-sm">
+            <span>{page.total} rows</span>
+            <div className="flex items-center gap-2">
+                <Button variant="outline" size="sm" disabled={page.page === 0} onClick={() => goTo(page.page - 1)}>
+                    Previous
+                </Button>
+                <span>Page {page.page + 1} of {pages}</span>
+                <Button variant="outline" size="sm" disabled={page.page + 1 >= pages} onClick={() => goTo(page.page + 1)}>
+                    Next
+                </Button>
+            </div>
+        </div>
+    )
+}
+
+export const TableHeading = ({columns, query, sortBy, before}) => (
+    <thead>
+    <tr className="border-b">
+        {before}
+        {columns.map((column) => (
+            <th key={column.key} className="text-left font-bold py-2 pr-4 cursor-pointer select-none"
+                onClick={() => sortBy(column.key)}>
+                {column.label}
+                {query.sortBy === column.key ? (query.desc ? ' ▼' : ' ▲') : ''}
+            </th>
+        ))}
+    </tr>
+    </thead>
+)
+
+export const TableDisplay = ({id, label, columns, page: firstPage}) => {
+    const {page, query, sortBy, goTo, error} = useTablePage(id, firstPage)
+
+    return (
+        <Card>
+            <CardHeader className="font-bold">{label}</CardHeader>
+            <CardContent className="overflow-x-auto">
+                {error && <p className="text-sm text-red-700">{error}</p>}
+                <table className="table-auto w-full text-sm">
+                    <TableHeading columns={columns} query={query} sortBy={sortBy}/>
+                    <tbody>
+                    {page.rows.map((row, i) => (
+                        <tr key={i} className="border-b last:border-0">
+                            {row.map((cell, j) => <td key={j} className="py-2 pr-4">{cell}</td>)}
+                        </tr>
+                    ))}
+                    </tbody>
+                </table>
+                <TablePager page={page} goTo={goTo}/>
+            </CardContent>
+        </Card>
+    )
+}
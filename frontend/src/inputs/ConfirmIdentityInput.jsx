@@ -0,0 +1,30 @@
+import React, {useState} from 'react';
+import {Commitable, useRun} from "../util/components.jsx";
+import {Label} from "../ui/Label.jsx";
+import {Input} from "../ui/Input.jsx";
+
+export const ConfirmIdentityInput = ({message, instructions, error}) => {
+    const {sendInput} = useRun();
+    const [code, setCode] = useState('');
+
+    const handleCommit = () => {
+        if (!code.trim()) {
+            alert('Please enter your code');
+            return false;
+        }
+        sendInput(code);
+        return true;
+    };
+
+    return (
+        <Commitable onCommit={handleCommit} content={
+            <>
+                <Label>{message}</Label>
+                {error && <p className="text-sm text-red-700">{error}</p>}
+                <Input type="text" inputMode="numeric" autoComplete="one-time-code"
+                       value={code} onChange={(e) => setCode(e.target.value)}/>
+                <p className="text-sm">{instructions}</p>
+            </>
+        }/>
+    );
+};
@@ -0,0 +1,46 @@
+import React, {useContext, useState} from 'react';
+import {CardContext, useRun} from "../util/components.jsx";
+import {Button} from "../ui/Button.jsx";
+import {Card, CardContent} from "../ui/Card.jsx";
+
+// ConfirmInput takes over the screen until the user makes a choice, once answered it stays on the page as a small card
+export const ConfirmInput = ({message, helpText, danger, confirmLabel, cancelLabel}) => {
+    const {sendInput} = useRun();
+    const {answered} = useContext(CardContext);
+    const [answer, setAnswer] = useState(null);
+
+    const choose = (value) => {
+        setAnswer(value);
+        sendInput(value);
+    };
+
+    if (answered || answer !== null) {
+        return (
+            <Card>
+                <CardContent className="pt-6 flex flex-col gap-1">
+                    <p className="font-bold">{message}</p>
+                    <p className="text-sm text-gray-500">
+                        {answer === null ? 'Answered' : (answer ? 'Confirmed' : 'Cancelled')}
+                    </p>
+                </CardContent>
+            </Card>
+        );
+    }
+
+    return (
+        <div className="fixed inset-0 z-50 flex items-center justify-center bg-black/60" role="dialog" aria-modal="true">
+            <div className={`w-full max-w-lg rounded-lg bg-white p-6 shadow-xl flex flex-col gap-4 ${danger ? 'border-4 border-red-600' : ''}`}>
+                <h2 className={`text-2xl font-bold ${danger ? 'text-red-700' : ''}`}>{message}</h2>
+                {helpText && <p className="text-sm text-gray-600">{helpText}</p>}
+                <div className="flex justify-end gap-2">
+                    <Button variant="outline" onClick={() => choose(false)} autoFocus>
+                        {cancelLabel || 'Cancel'}
+                    </Button>
+                    <Button variant={danger ? 'destructive' : 'default'} onClick={() => choose(true)}>
+                        {confirmLabel || 'Confirm'}
+                    </Button>
+                </div>
+            </div>
+        </div>
+    );
+};
@@ -1,20 +1,30 @@
 import React, { useState } from 'react';
 import DatePicker from 'react-datepicker'; // You'll need to install this package
 import 'react-datepicker/dist/react-datepicker.css';
-import {Commitable} from "../util/components.jsx";
-import {useAppState} from "../util/state.js";
+import {Commitable, useSendInput} from "../util/components.jsx";
 import {Label} from "../ui/Label.jsx";
 
-export const DateInput = ({ label, helpText, min, max }) => {
-    const [selectedDate, setSelectedDate] = useState(null);
-    const {sendInput} = useAppState();
+// the server sends and takes dates as YYYY-MM-DD, they are read and written in local time so the day doesn't shift
+const parseDate = (s) => {
+    const [year, month, day] = s.split('-').map(Number)
+    return new Date(year, month - 1, day)
+}
+const formatDate = (d) => [
+    d.getFullYear(),
+    String(d.getMonth() + 1).padStart(2, '0'),
+    String(d.getDate()).padStart(2, '0'),
+].join('-')
+
+export const DateInput = ({ label, helpText, min, max, defaultValue }) => {
+    const [selectedDate, setSelectedDate] = useState(defaultValue ? parseDate(defaultValue) : null);
+    const sendInput = useSendInput();
     const handleChange = (date) => {
         setSelectedDate(date);
     };
 
     const handleCommit = () => {
         if (selectedDate) {
-            sendInput(selectedDate.toISOString().split('T')[0])
+            sendInput(formatDate(selectedDate))
             return true;
         }
         return false;
@@ -27,8 +37,8 @@ export const DateInput = ({ label, helpText, min, max }) => {
                 <DatePicker
                     selected={selectedDate}
                     onChange={handleChange}
-                    minDate={new Date(min)}
-                    maxDate={new Date(max)}
+                    minDate={min ? parseDate(min) : null}
+                    maxDate={max ? parseDate(max) : null}
                     className="flex h-10 w-full rounded-md border border-input bg-background px-3 py-2 text-sm ring-offset-background file:border-0 file:bg-transparent file:text-sm file:font-medium file:text-foreground placeholder:text-muted-foreground focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-ring focus-visible:ring-offset-2 disabled:cursor-not-allowed disabled:opacity-50"
                  showMonthYearDropdown/>
                 <p className="text-sm">{helpText}</p>
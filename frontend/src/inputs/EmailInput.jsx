@@ -1,12 +1,11 @@
 import React, { useState } from 'react';
-import {Commitable} from "../util/components.jsx";
-import {useAppState} from "../util/state.js";
+import {Commitable, useSendInput} from "../util/components.jsx";
 import {Input} from "../ui/Input.jsx";
 import {Label} from "../ui/Label.jsx";
 
-export const EmailInput = ({ label, helpText, placeholder, required }) => {
-    const {sendInput} = useAppState();
-    const [value, setValue] = useState('');
+export const EmailInput = ({ label, helpText, placeholder, required, defaultValue }) => {
+    const sendInput = useSendInput();
+    const [value, setValue] = useState(defaultValue ?? '');
 
     const handleChange = (e) => {
         setValue(e.target.value);
@@ -1,27 +1,76 @@
 import React, {useRef, useState} from 'react';
-import {Commitable} from "../util/components.jsx";
-import {useAppState} from "../util/state.js";
+import {Commitable, useRun} from "../util/components.jsx";
+import {csrfToken} from "../util/state.js";
 import {Label} from "../ui/Label.jsx";
 import {Input} from "../ui/Input.jsx";
 
-export const FileInput = ({ label, helpText, accept, multiple }) => {
+// files are sent in chunks so large files don't need to fit in a single request
+const CHUNK_SIZE = 1 << 20;
+
+const uploadFile = async (uploadId, index, file, onProgress) => {
+    let offset = 0;
+    do {
+        const chunk = file.slice(offset, offset + CHUNK_SIZE);
+        const params = new URLSearchParams({
+            file: index,
+            name: file.name,
+            type: file.type,
+            size: file.size,
+            offset,
+        });
+        const res = await fetch(`${window.location.pathname}/upload/${uploadId}?${params}`, {
+            method: 'POST',
+            headers: {'X-BFF-CSRF': csrfToken},
+            body: chunk,
+        });
+        if (!res.ok) {
+            throw new Error(`${file.name}: ${(await res.text()).trim() || res.statusText}`);
+        }
+        offset += chunk.size;
+        onProgress(offset);
+    } while (offset < file.size);
+};
+
+export const FileInput = ({ label, helpText, accept, multiple, maxSize, maxFiles, uploadId }) => {
     const fileInputRef = useRef(null);
     const [files, setFiles] = useState([]);
-    const {sendInput} = useAppState();
+    const [uploaded, setUploaded] = useState(0);
+    const [error, setError] = useState(null);
+    const {sendInput} = useRun();
 
     const handleChange = (e) => {
         const files = Array.from(e.target.files);
+        setError(null);
+        const tooLarge = files.find(file => maxSize && file.size > maxSize);
+        if (tooLarge) {
+            setError(`${tooLarge.name} is larger than ${maxSize} bytes`);
+        }
+        if (maxFiles && files.length > maxFiles) {
+            setError(`Pick at most ${maxFiles} files`);
+        }
         setFiles(files);
-        console.log('TODO send files', files)
         return true;
     };
-    const commit = () => {
-        console.log('TODO upload files to the backend', files)
-        console.log('TODO notify the script that a file has been uploaded')
-        sendInput(files.map(file => file.name))
+    const commit = async () => {
+        if (error) {
+            return false;
+        }
+        setUploaded(0);
+        let done = 0;
+        try {
+            for (const [index, file] of files.entries()) {
+                await uploadFile(uploadId, index, file, (offset) => setUploaded(done + offset));
+                done += file.size;
+            }
+        } catch (e) {
+            setError(e.message);
+            return false;
+        }
+        sendInput(files.map((_, index) => index))
         return true;
     }
 
+    const total = files.reduce((sum, file) => sum + file.size, 0);
     return (
         <Commitable onCommit={commit} content={
             <>
@@ -33,6 +82,10 @@ export const FileInput = ({ label, helpText, accept, multiple }) => {
                     accept={accept}
                     multiple={multiple}
                 />
+                {uploaded > 0 && total > 0 && (
+                    <progress className="w-full" value={uploaded} max={total}/>
+                )}
+                {error && <p className="text-sm text-red-600">{error}</p>}
                 <p className="text-sm">{helpText}</p>
             </>
         } />
@@ -1,10 +1,9 @@
 import React, { useState, useEffect } from 'react';
-import {Commitable} from "../util/components.jsx";
-import {useAppState} from "../util/state.js";
+import {Commitable, useSendInput} from "../util/components.jsx";
 import {Label} from "../ui/Label.jsx";
 
 export const RichTextInput = ({ label, helpText, initialValue }) => {
-    const {sendInput} = useAppState();
+    const sendInput = useSendInput();
 
     let handleCommit = () => {
         console.log('submitting the rich text state...')
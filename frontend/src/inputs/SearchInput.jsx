@@ -0,0 +1,65 @@
+import React, {useEffect, useState} from 'react';
+import {Commitable, useRun} from "../util/components.jsx";
+import {Label} from "../ui/Label.jsx";
+import {Input} from "../ui/Input.jsx";
+
+// how long to wait after the last keystroke before searching
+const debounceMs = 300
+
+export const SearchInput = ({id, label, helpText, placeholder, initialResults}) => {
+    const {sendInput, sendQuery, queryResults} = useRun();
+    const [query, setQuery] = useState('');
+    const [selected, setSelected] = useState(null);
+
+    useEffect(() => {
+        if (query === '') {
+            // the initial results came with the input
+            return
+        }
+        const timer = setTimeout(() => sendQuery(id, query), debounceMs)
+        return () => clearTimeout(timer)
+    }, [id, query]);
+
+    const latest = queryResults[id]
+    // only show results for what is in the box, an older search may answer after a newer one
+    const current = latest?.result?.query === query ? latest.result : null
+    const results = current || (query === '' ? {query: '', results: initialResults} : null)
+
+    const handleCommit = () => {
+        if (!selected) {
+            alert('Please select a result');
+            return false;
+        }
+        sendInput(selected);
+        return true;
+    };
+
+    return (
+        <Commitable onCommit={handleCommit} content={
+            <>
+                <Label>{label}</Label>
+                <Input type="search" value={query} placeholder={placeholder}
+                       onChange={(e) => setQuery(e.target.value)}/>
+                {latest?.error && <p className="text-sm text-red-700">{latest.error}</p>}
+                <ul className="flex flex-col gap-1">
+                    {(results?.results || []).map((result, index) => {
+                        const isSelected = selected && selected.query === results.query && selected.index === index
+                        return (
+                            <li key={index}
+                                className={`flex items-center gap-3 p-2 rounded-md cursor-pointer ${isSelected ? 'bg-blue-100' : 'hover:bg-gray-100'}`}
+                                onClick={() => setSelected({query: results.query, index})}>
+                                {result.imageUrl && <img src={result.imageUrl} alt="" className="w-8 h-8 rounded-full"/>}
+                                <div>
+                                    <div className="font-bold">{result.label}</div>
+                                    {result.description && <div className="text-sm text-gray-500">{result.description}</div>}
+                                </div>
+                            </li>
+                        )
+                    })}
+                </ul>
+                {results === null && <p className="text-sm text-gray-500">Searching...</p>}
+                <p className="text-sm">{helpText}</p>
+            </>
+        }/>
+    );
+};
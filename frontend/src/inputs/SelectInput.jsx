@@ -0,0 +1,49 @@
+import React, {useState} from 'react';
+import {Commitable, useSendInput} from "../util/components.jsx";
+import {Label} from "../ui/Label.jsx";
+
+// SelectInput picks one or many of the options, the answer is the position of the options picked
+export const SelectInput = ({label, helpText, required, options, multiple}) => {
+    const sendInput = useSendInput();
+    const defaults = options.map((option, i) => option.default ? i : -1).filter((i) => i >= 0)
+    const [selected, setSelected] = useState(multiple ? defaults : defaults.slice(0, 1));
+
+    const toggle = (index) => {
+        if (!multiple) {
+            setSelected([index])
+            return
+        }
+        setSelected((s) => s.includes(index) ? s.filter((i) => i !== index) : [...s, index])
+    }
+
+    const handleCommit = () => {
+        if ((required || !multiple) && selected.length === 0) {
+            alert('Please make a selection');
+            return false;
+        }
+        sendInput(multiple ? selected : selected[0]);
+        return true;
+    };
+
+    return (
+        <Commitable onCommit={handleCommit} content={
+            <>
+                <Label>{label}</Label>
+                <div className="flex flex-col gap-2">
+                    {options.map((option, i) => (
+                        <label key={i} className="flex items-start gap-2 cursor-pointer">
+                            <input type={multiple ? 'checkbox' : 'radio'} className="mt-1"
+                                   checked={selected.includes(i)} onChange={() => toggle(i)}/>
+                            <span>
+                                <span className="font-medium">{option.label}</span>
+                                {option.description &&
+                                    <span className="block text-sm text-gray-500">{option.description}</span>}
+                            </span>
+                        </label>
+                    ))}
+                </div>
+                <p className="text-sm">{helpText}</p>
+            </>
+        }/>
+    );
+};
@@ -0,0 +1,60 @@
+import React, {useState} from 'react';
+import {Commitable, useRun} from "../util/components.jsx";
+import {Label} from "../ui/Label.jsx";
+import {TableHeading, TablePager, useTablePage} from "../displays/TableDisplay.jsx";
+
+export const SelectTableInput = ({label, helpText, table, minSelections, maxSelections}) => {
+    const {sendInput} = useRun();
+    const {page, query, sortBy, goTo, error} = useTablePage(table.id, table.page)
+    // selected holds the indexes of the rows in the original data, so it survives paging and sorting
+    const [selected, setSelected] = useState([]);
+
+    const toggle = (index) => {
+        setSelected((s) => s.includes(index) ? s.filter((i) => i !== index) : [...s, index])
+    }
+
+    const handleCommit = () => {
+        if (minSelections && selected.length < minSelections) {
+            alert(`Please select at least ${minSelections} rows`);
+            return false;
+        }
+        if (maxSelections && selected.length > maxSelections) {
+            alert(`Please select at most ${maxSelections} rows`);
+            return false;
+        }
+        sendInput(selected);
+        return true;
+    };
+
+    return (
+        <Commitable onCommit={handleCommit} content={
+            <>
+                <Label>{label}</Label>
+                {error && <p className="text-sm text-red-700">{error}</p>}
+                <div className="overflow-x-auto">
+                    <table className="table-auto w-full text-sm">
+                        <TableHeading columns={table.columns} query={query} sortBy={sortBy}
+                                      before={<th className="w-8"/>}/>
+                        <tbody>
+                        {page.rows.map((row, i) => {
+                            const index = page.indexes[i]
+                            return (
+                                <tr key={index} className="border-b last:border-0 cursor-pointer"
+                                    onClick={() => toggle(index)}>
+                                    <td className="py-2 pr-2">
+                                        <input type="checkbox" readOnly checked={selected.includes(index)}/>
+                                    </td>
+                                    {row.map((cell, j) => <td key={j} className="py-2 pr-4">{cell}</td>)}
+                                </tr>
+                            )
+                        })}
+                        </tbody>
+                    </table>
+                </div>
+                <TablePager page={page} goTo={goTo}/>
+                <p className="text-sm">{selected.length} selected</p>
+                <p className="text-sm">{helpText}</p>
+            </>
+        }/>
+    );
+};
@@ -1,12 +1,11 @@
 import React, { useState } from 'react';
 import Slider from 'react-slider';
-import {Commitable} from "../util/components.jsx";
-import {useAppState} from "../util/state.js";
+import {Commitable, useSendInput} from "../util/components.jsx";
 import {Label} from "../ui/Label.jsx"; // You'll need to install this package
 
-export const SliderInput = ({ label, helpText, min, max, step }) => {
-    const [value, setValue] = useState(min);
-    const {sendInput} = useAppState();
+export const SliderInput = ({ label, helpText, min, max, step, defaultValue }) => {
+    const [value, setValue] = useState(defaultValue ?? min);
+    const sendInput = useSendInput();
 
     const handleChange = (newValue) => {
         setValue(newValue);
@@ -1,11 +1,10 @@
 import React, {useState} from 'react';
-import {Commitable} from "../util/components.jsx";
-import {useAppState} from "../util/state.js";
+import {Commitable, useSendInput} from "../util/components.jsx";
 import {Label} from "../ui/Label.jsx";
 
-export const TextAreaInput = ({label, helpText, placeholder, required}) => {
-    const {sendInput} = useAppState();
-    const [value, setValue] = useState('');
+export const TextAreaInput = ({label, helpText, placeholder, required, initialValue}) => {
+    const sendInput = useSendInput();
+    const [value, setValue] = useState(initialValue ?? '');
 
     const handleChange = (e) => {
         setValue(e.target.value);
@@ -1,14 +1,13 @@
 import React, { useState } from 'react';
 import TimePicker from 'react-time-picker'; // You'll need to install this package
 import 'react-time-picker/dist/TimePicker.css';
-import {Commitable} from "../util/components.jsx";
-import {useAppState} from "../util/state.js";
+import {Commitable, useSendInput} from "../util/components.jsx";
 import {Label} from "../ui/Label.jsx";
 
 
-export const TimeInput = ({ label, helpText, min, max, onCommit }) => {
-    const [time, setTime] = useState('12:00');
-    const {sendInput} = useAppState();
+export const TimeInput = ({ label, helpText, min, max, defaultValue }) => {
+    const [time, setTime] = useState(defaultValue ?? '12:00');
+    const sendInput = useSendInput();
     const handleChange = (newTime) => {
         setTime(newTime);
     };
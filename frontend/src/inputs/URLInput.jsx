@@ -1,12 +1,11 @@
 import React, { useState } from 'react';
-import {Commitable} from "../util/components.jsx";
-import {useAppState} from "../util/state.js";
+import {Commitable, useSendInput} from "../util/components.jsx";
 import {Input} from "../ui/Input.jsx";
 import {Label} from "../ui/Label.jsx";
 
-export const URLInput = ({ label, helpText, placeholder, required, onCommit }) => {
-    const [value, setValue] = useState('');
-    const {sendInput} = useAppState();
+export const URLInput = ({ label, helpText, placeholder, required, defaultValue }) => {
+    const [value, setValue] = useState(defaultValue ?? '');
+    const sendInput = useSendInput();
     const handleChange = (e) => {
         setValue(e.target.value);
     };
@@ -1,9 +1,67 @@
-import React, {useState} from "react";
+import React, {createContext, useContext, useEffect, useState} from "react";
 import {Card, CardContent, CardFooter, CardHeader} from "../ui/Card.jsx";
 import {Button} from "../ui/Button.jsx";
+import {useAppState} from "./state.js";
+
+// CardContext lets a card know it was answered before, I.E when the session history is replayed after a reconnect,
+// why the server turned down the last answer and if the server gave up waiting for an answer
+export const CardContext = createContext({answered: false, error: null, expired: false})
+
+// GroupContext is set for the inputs in a group, rather than each having a card and sending its own answer they
+// hand their commit and answer to the group, which sends them all at once. See GroupDisplay.
+export const GroupContext = createContext(null)
+
+// RunContext is the id of the run the cards belong to, what they send is for that run
+export const RunContext = createContext(null)
+
+// useRun is the state of the run the card belongs to, with sendInput and sendQuery for it
+export const useRun = () => {
+    const run = useContext(RunContext);
+    const {runs, sendInput, sendQuery} = useAppState();
+    return {
+        ...runs[run],
+        sendInput: (value) => sendInput(run, value),
+        sendQuery: (id, query) => sendQuery(run, id, query),
+    };
+}
+
+// useSendInput is sendInput for inputs that can be in a group
+export const useSendInput = () => {
+    const group = useContext(GroupContext);
+    const {sendInput} = useRun();
+    return group ? group.send : sendInput;
+}
 
 export const Commitable = ({onCommit, content}) => {
-    const [hasCommitted, setHasCommitted] = useState(false);
+    const {answered, error, expired} = useContext(CardContext);
+    const group = useContext(GroupContext);
+    const [committed, setHasCommitted] = useState(false);
+    const hasCommitted = committed || answered;
+
+    // a rejected answer opens the card back up so it can be corrected, every rejection is a new error object
+    useEffect(() => {
+        if (error) {
+            setHasCommitted(false);
+        }
+    }, [error]);
+
+    // the group calls the latest onCommit when it is submitted
+    useEffect(() => {
+        if (group) {
+            group.setCommit(onCommit);
+        }
+    });
+
+    if (group) {
+        return (
+            <div className={"flex flex-col gap-4"}>
+                {React.isValidElement(content) ? content : null}
+                {error?.message && (
+                    <p className={"text-sm text-red-700"}>{error.message}</p>
+                )}
+            </div>
+        );
+    }
 
     return (
         <Card>
@@ -11,13 +69,19 @@ export const Commitable = ({onCommit, content}) => {
             <CardContent className={"flex flex-col gap-4"}>
                 {React.isValidElement(content) ? content : null}
             </CardContent>
-            <CardFooter>
-                {hasCommitted ? (
+            <CardFooter className={"flex flex-col items-start gap-2"}>
+                {error?.message && !hasCommitted && (
+                    <p className={"text-sm text-red-700"}>{error.message}</p>
+                )}
+                {expired && !hasCommitted ? (
+                    <p className={"text-sm text-gray-500"}>No answer was given in time</p>
+                ) : hasCommitted ? (
                     <p className={"text-sm text-gray-500"}>Submitted</p>
                 ) : (
                     <Button
-                        onClick={() => {
-                            if (onCommit()) {
+                        onClick={async () => {
+                            // onCommit can be async, I.E when files need uploading before the input is sent
+                            if (await onCommit()) {
                                 setHasCommitted(true);
                             }
                         }}
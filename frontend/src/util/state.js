@@ -4,20 +4,71 @@ export const backend = `${window.location.host}${window.location.pathname}/ws`
 
 export const actionName = window.location.pathname.split('/').pop()
 
+// the server puts a token in the page that has to be sent back with sockets and uploads, so other sites can't open them
+export const csrfToken = document.querySelector('meta[name="bff-csrf"]')?.content ?? ''
+
+// the session is remembered per tab, so a dropped connection can pick up the running action where it left off
+const sessionKey = `bff-session:${window.location.pathname}`
+export const loadSessionId = () => window.sessionStorage.getItem(sessionKey)
+export const saveSessionId = (id) => window.sessionStorage.setItem(sessionKey, id)
+
+// the version of the protocol the app speaks, the server says which version it will speak in the session message
+export const protocolVersion = 1
+
+// other actions to run alongside the one in the path, I.E ?with=refund,find_customer shows them side by side
+export const alongside = new URLSearchParams(window.location.search).get('with')?.split(',').filter(Boolean) ?? []
+
+// newRun is the state of one run of an action, the server tags every message about it with its id
+export const newRun = (action) => ({
+    action,
+    cards: [],
+    // the latest answer to each query, by the id of the element that was queried
+    queryResults: {},
+    // the progress of the run, see LoadingDisplay
+    loading: null,
+    // null while it is running, then done, cancelled or error
+    status: null,
+    error: null,
+})
+
 export const useAppState = create((set, get) => ({
     pages: [],
     actions: [],
-    currentAction: null,
-    cards: [],
+    // the runs by their id, and the order they were started in
+    runs: {},
+    runOrder: [],
     history: [],
+    // the protocol version of the connection, see the session message
+    version: 0,
+    // post puts the message in the envelope of the protocol and sends it, the message is returned as it was sent
+    post: (msg) => {
+        const {version, socket} = get()
+        const sent = {...msg, id: crypto.randomUUID(), version}
+        socket.send(JSON.stringify(sent))
+        return sent
+    },
     startAction: (name) => {
-        const msg = {type: 'start', data: name}
-        set((state) => ({...state, history: [...state.history, msg], currentAction: name,  cards: []}))
-        get().socket.send(JSON.stringify(msg))
+        const run = crypto.randomUUID()
+        const msg = get().post({type: 'start', data: name, run})
+        set((state) => ({
+            ...state,
+            history: [...state.history, msg],
+            runs: {...state.runs, [run]: newRun(name)},
+            runOrder: [...state.runOrder, run],
+        }))
+    },
+    sendQuery: (run, id, query) => {
+        // queries aren't kept in the history, there can be a lot of them
+        get().post({type: 'query', run, data: {id, query}})
+    },
+    cancelAction: (run) => {
+        // the server answers with cancelled once the handler has given up
+        get().post({type: 'cancel', run})
     },
-    sendInput: (value) => {
-        const msg = {type: 'input', data: value}
+    sendInput: (run, value) => {
+        // the answer is for the last card of the run, the server turns it down if that isn't what it is waiting on
+        const inReplyTo = get().runs[run]?.cards.at(-1)?.id
+        const msg = get().post({type: 'input', run, data: value, inReplyTo})
         set((state) => ({...state, history: [...state.history, msg]}))
-        get().socket.send(JSON.stringify(msg))
     }
 }))
@@ -2,4 +2,7 @@ module github.com/ebuckley/bff
 
 go 1.23
 
-require github.com/coder/websocket v1.8.12
+require (
+	github.com/coder/websocket v1.8.12
+	golang.org/x/crypto v0.33.0
+)
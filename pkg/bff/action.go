@@ -3,23 +3,43 @@ package bff
 import (
 	"context"
 	"errors"
+	"slices"
+	"time"
 )
 
 var ErrActionAlreadyExists = errors.New("action already exists")
 var ErrActionNotFound = errors.New("action not found")
+var ErrForbidden = errors.New("not allowed to run this action")
+var ErrRunBusy = errors.New("run has too many answers waiting to be read")
+var ErrActionTimeout = errors.New("action ran past its deadline")
 
 type HandlerFunc func(ctx context.Context, io *Io) error
 
+// ResultHandlerFunc is a handler that returns a value when it is done, the value is shown to the user, kept in the run
+// log and handed back to ExecuteActionWithResult
+type ResultHandlerFunc func(ctx context.Context, io *Io) (any, error)
+
 type Action struct {
-	handler     HandlerFunc
+	handler     ResultHandlerFunc
 	Slug        string `json:"slug,omitempty"`
 	Name        string `json:"name,omitempty"`
 	Description string `json:"description,omitempty"`
+	// Roles are the roles allowed to run the action, anyone can run it when there are none
+	Roles []string `json:"roles,omitempty"`
+	// timeout is how long a run can take before it is cancelled, see WithActionTimeout
+	timeout time.Duration
 }
 
 type ActionOption func(*Action)
 
 func NewAction(name string, handler HandlerFunc, opts ...ActionOption) *Action {
+	return NewActionWithResult(name, func(ctx context.Context, io *Io) (any, error) {
+		return nil, handler(ctx, io)
+	}, opts...)
+}
+
+// NewActionWithResult creates an action whose handler returns a value
+func NewActionWithResult(name string, handler ResultHandlerFunc, opts ...ActionOption) *Action {
 	action := &Action{
 		Name:    name,
 		Slug:    name,
@@ -41,3 +61,38 @@ func WithDescription(description string) ActionOption {
 		a.Description = description
 	}
 }
+
+// WithRoles only lets users with one of the roles run the action
+func WithRoles(roles ...string) ActionOption {
+	return func(a *Action) {
+		a.Roles = roles
+	}
+}
+
+// WithActionTimeout cancels a run that takes longer than d, the context of the handler is cancelled and the run fails
+// with ErrActionTimeout. Time spent waiting for answers counts too.
+func WithActionTimeout(d time.Duration) ActionOption {
+	return func(a *Action) {
+		a.timeout = d
+	}
+}
+
+// Policy decides whether the user can run the action, user is nil when nobody is signed in
+type Policy func(ctx context.Context, user *User, action *Action) bool
+
+// RolePolicy is the default Policy, actions without roles are open to everyone and the rest need the user to have
+// one of their roles
+func RolePolicy(ctx context.Context, user *User, action *Action) bool {
+	if len(action.Roles) == 0 {
+		return true
+	}
+	if user == nil {
+		return false
+	}
+	for _, role := range action.Roles {
+		if slices.Contains(user.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,443 @@
+package bff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExecuteActionWithResult(t *testing.T) {
+	runs := NewMemoryRunStore()
+	b := New(WithRunStore(runs))
+	type refund struct {
+		Charge string
+		Amount float64
+	}
+	err := b.RegisterActionWithResult("refund", func(ctx context.Context, io *Io) (any, error) {
+		return []refund{{Charge: "ch_1", Amount: 12.5}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.RegisterActionWithResult("count", func(ctx context.Context, io *Io) (any, error) {
+		return map[string]int{"refunds": 1}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := make(chan Message, 1)
+	result, err := b.ExecuteActionWithResult(context.Background(), "refund", nil, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refunds, ok := result.([]refund); !ok || len(refunds) != 1 || refunds[0].Charge != "ch_1" {
+		t.Errorf("expected the refunds back, got %+v", result)
+	}
+	if m := <-output; m.Type != "table" {
+		t.Errorf("expected a list to be shown as a table, got %s", m.Type)
+	}
+
+	_, err = b.ExecuteActionWithResult(context.Background(), "count", nil, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := <-output; m.Type != "object" {
+		t.Errorf("expected a map to be shown as an object, got %s", m.Type)
+	}
+
+	// nothing to show the result on, it is only returned
+	result, err = b.ExecuteActionWithResult(context.Background(), "count", nil, nil)
+	if err != nil || result == nil {
+		t.Errorf("expected the result without an output, got %v %v", result, err)
+	}
+
+	list, err := runs.List(context.Background(), RunFilter{Action: "refund"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, ok := list[0].Result.([]any)
+	if !ok || len(rows) != 1 || rows[0].(map[string]any)["Charge"] != "ch_1" {
+		t.Errorf("expected the result in the run log, got %+v", list[0].Result)
+	}
+}
+
+func TestExecuteAction_Roles(t *testing.T) {
+	b := New()
+	err := b.RegisterAction("nuke", func(ctx context.Context, io *Io) error {
+		return nil
+	}, WithRoles("admin", "support"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.RegisterAction("hello", func(ctx context.Context, io *Io) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		user    *User
+		allowed bool
+	}{
+		{nil, false},
+		{&User{ID: "guest"}, false},
+		{&User{ID: "sam", Roles: []string{"support"}}, true},
+	} {
+		ctx := context.Background()
+		if c.user != nil {
+			ctx = ContextWithUser(ctx, c.user)
+		}
+		err := b.ExecuteAction(ctx, "nuke", nil, nil)
+		if c.allowed && err != nil {
+			t.Errorf("expected %+v to run the action, got %v", c.user, err)
+		}
+		if !c.allowed && !errors.Is(err, ErrForbidden) {
+			t.Errorf("expected %+v to be forbidden, got %v", c.user, err)
+		}
+		if actions := b.ActionsFor(ctx); len(actions) != map[bool]int{true: 2, false: 1}[c.allowed] {
+			t.Errorf("expected %+v to only see the actions they can run, got %d", c.user, len(actions))
+		}
+	}
+}
+
+func TestLoop_Cancel(t *testing.T) {
+	b := New()
+	handled := make(chan error, 1)
+	err := b.RegisterAction("backfill", func(ctx context.Context, io *Io) error {
+		_, err := io.Input.Text("How far back?")
+		handled <- err
+		if ctx.Err() == nil {
+			t.Error("expected the context of the handler to be cancelled")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input := make(chan Message)
+	output := make(chan Message)
+	go b.Loop(ctx, input, output)
+
+	input <- Message{Type: "start", Data: "backfill"}
+	if m := <-output; m.Type != "textInput" {
+		t.Fatalf("expected the prompt, got %+v", m)
+	}
+	input <- Message{Type: "cancel"}
+	if err := <-handled; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the prompt to give up with context.Canceled, got %v", err)
+	}
+	if m := <-output; m.Type != "cancelled" || m.Data != "backfill" {
+		t.Errorf("expected the run to be cancelled, got %+v", m)
+	}
+
+	// the loop carries on and can run the action again
+	input <- Message{Type: "start", Data: "backfill"}
+	if m := <-output; m.Type != "textInput" {
+		t.Fatalf("expected the prompt again, got %+v", m)
+	}
+}
+
+func TestExecuteAction_ContextCancelled(t *testing.T) {
+	b := New()
+	err := b.RegisterAction("backfill", func(ctx context.Context, io *Io) error {
+		_, err := io.Input.Number("How many days?")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	output := make(chan Message, 1)
+	done := make(chan error)
+	go func() {
+		done <- b.ExecuteAction(ctx, "backfill", make(chan Message), output)
+	}()
+	<-output
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestExecuteAction_Timeout(t *testing.T) {
+	runs := NewMemoryRunStore()
+	b := New(WithRunStore(runs))
+	err := b.RegisterAction("backfill", func(ctx context.Context, io *Io) error {
+		_, err := io.Input.Text("Which table?")
+		return err
+	}, WithActionTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.ExecuteAction(context.Background(), "backfill", make(chan Message), make(chan Message, 1))
+	if !errors.Is(err, ErrActionTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected ErrActionTimeout, got %v", err)
+	}
+	list, err := runs.List(context.Background(), RunFilter{Action: "backfill"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Error == "" {
+		t.Errorf("expected the run to be logged as failed, got %+v", list)
+	}
+}
+
+func TestLoop_ConcurrentRuns(t *testing.T) {
+	b := New()
+	err := b.RegisterAction("greet", func(ctx context.Context, io *Io) error {
+		name, err := io.Input.Text("What is your name?")
+		if err != nil {
+			return err
+		}
+		io.Display.Heading("Hello "+name, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input := make(chan Message)
+	output := make(chan Message)
+	go b.Loop(ctx, input, output)
+
+	input <- Message{Type: "start", Run: "left", Data: "greet"}
+	if m := <-output; m.Type != "textInput" || m.Run != "left" {
+		t.Fatalf("expected the prompt for the left run, got %+v", m)
+	}
+	input <- Message{Type: "start", Run: "right", Data: "greet"}
+	if m := <-output; m.Type != "textInput" || m.Run != "right" {
+		t.Fatalf("expected the prompt for the right run, got %+v", m)
+	}
+	input <- Message{Type: "start", Run: "left", Data: "greet"}
+	if m := <-output; m.Type != "error" || m.Run != "left" {
+		t.Errorf("expected a run ID that is in use to be refused, got %+v", m)
+	}
+
+	// answered in the other order to how they were started
+	input <- Message{Type: "input", Run: "right", Data: "bob"}
+	for _, expected := range []Message{
+		{Type: "display", Run: "right", Data: HeadingDisplay{Text: "Hello bob", Level: 1}},
+		{Type: "done", Run: "right", Data: "greet"},
+	} {
+		m := <-output
+		if m.ID == "" {
+			t.Errorf("expected every message to have an ID, got %+v", m)
+		}
+		m.ID = ""
+		if m != expected {
+			t.Errorf("expected %+v, got %+v", expected, m)
+		}
+	}
+	input <- Message{Type: "input", Run: "left", Data: "alice"}
+	for _, expected := range []Message{
+		{Type: "display", Run: "left", Data: HeadingDisplay{Text: "Hello alice", Level: 1}},
+		{Type: "done", Run: "left", Data: "greet"},
+	} {
+		m := <-output
+		if m.ID == "" {
+			t.Errorf("expected every message to have an ID, got %+v", m)
+		}
+		m.ID = ""
+		if m != expected {
+			t.Errorf("expected %+v, got %+v", expected, m)
+		}
+	}
+
+	// without a run ID the loop picks one, and answers go to the run started last
+	input <- Message{Type: "start", Data: "greet"}
+	m := <-output
+	if m.Type != "textInput" || m.Run == "" || m.Run == "left" || m.Run == "right" {
+		t.Fatalf("expected the prompt for a new run, got %+v", m)
+	}
+	input <- Message{Type: "input", Data: "carol"}
+	if m := <-output; m.Run == "" || m.Data != (HeadingDisplay{Text: "Hello carol", Level: 1}) {
+		t.Errorf("expected the answer to go to the latest run, got %+v", m)
+	}
+}
+
+func TestLoop_StaleAnswers(t *testing.T) {
+	b := New()
+	release := make(chan struct{})
+	err := b.RegisterAction("greet", func(ctx context.Context, io *Io) error {
+		io.Display.Heading("Welcome", 1)
+		<-release
+		_, err := io.Input.Text("Nickname?", WithTimeout(10*time.Millisecond))
+		if !errors.Is(err, ErrInputTimeout) {
+			t.Errorf("expected the nickname to time out, got %v", err)
+		}
+		name, err := io.Input.Text("What is your name?", WithMinLength(2))
+		if err != nil {
+			return err
+		}
+		io.Display.Heading("Hello "+name, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input := make(chan Message)
+	output := make(chan Message)
+	go b.Loop(ctx, input, output)
+
+	input <- Message{Type: "start", Data: "greet", ID: "start"}
+	heading := <-output
+	// only answers are given to the handler, anything else it wouldn't know what to do with
+	input <- Message{Type: "bogus", ID: "bogus"}
+	if m := <-output; m.Type != "rejected" || m.InReplyTo != "bogus" || m.Data != ErrUnknownMessage.Error() {
+		t.Fatalf("expected an unknown message to be rejected, got %+v", m)
+	}
+	// a display isn't waiting on an answer, one in reply to it mustn't be kept for the next prompt
+	input <- Message{Type: "input", Data: "mallory", ID: "early", InReplyTo: heading.ID}
+	if m := <-output; m.Type != "rejected" || m.InReplyTo != "early" {
+		t.Fatalf("expected an answer to a display to be rejected, got %+v", m)
+	}
+	close(release)
+	nickname := <-output
+	if m := <-output; m.Type != "inputTimeout" || m.InReplyTo != nickname.ID {
+		t.Fatalf("expected the nickname to time out, got %+v", m)
+	}
+	name := <-output
+	if name.Type != "textInput" || name.ID == nickname.ID {
+		t.Fatalf("expected the name prompt, got %+v", name)
+	}
+
+	// the answer to the nickname comes in too late, it must not be taken as the name
+	input <- Message{Type: "input", Data: "gopher", ID: "late", InReplyTo: nickname.ID}
+	if m := <-output; m.Type != "rejected" || m.InReplyTo != "late" || m.Data != ErrStaleAnswer.Error() {
+		t.Fatalf("expected the late answer to be rejected, got %+v", m)
+	}
+
+	input <- Message{Type: "input", Data: "a", ID: "short", InReplyTo: name.ID}
+	if m := <-output; m.Type != "validationError" || m.InReplyTo != name.ID {
+		t.Fatalf("expected the name to be too short, got %+v", m)
+	}
+	// turned down answers can be fixed
+	input <- Message{Type: "input", Data: "alice", ID: "fixed", InReplyTo: name.ID}
+	if m := <-output; m.Data != (HeadingDisplay{Text: "Hello alice", Level: 1}) {
+		t.Fatalf("expected the fixed name to be taken, got %+v", m)
+	}
+	if m := <-output; m.Type != "done" {
+		t.Fatalf("expected done, got %+v", m)
+	}
+	// the run is over, so is its prompt
+	input <- Message{Type: "input", Data: "alice", ID: "again", InReplyTo: name.ID}
+	if m := <-output; m.Type != "rejected" || m.InReplyTo != "again" {
+		t.Errorf("expected an answer to a finished run to be rejected, got %+v", m)
+	}
+}
+
+// approvalPrompt is a custom element, its name doesn't say it is an input and it doesn't send itself
+type approvalPrompt struct{}
+
+func (approvalPrompt) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "approval", Data: map[string]string{"label": "Approve?"}}, nil)
+}
+
+func TestLoop_CustomPrompts(t *testing.T) {
+	b := New()
+	err := b.RegisterAction("approve", func(ctx context.Context, io *Io) error {
+		approved, err := io.AddToStack(approvalPrompt{})
+		if err != nil {
+			return err
+		}
+		io.Display.Heading(approved.(string), 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input := make(chan Message)
+	output := make(chan Message)
+	go b.Loop(ctx, input, output)
+
+	input <- Message{Type: "start", Data: "approve"}
+	prompt := <-output
+	input <- Message{Type: "input", Data: "yes", ID: "answer", InReplyTo: prompt.ID}
+	if m := <-output; m.Data != (HeadingDisplay{Text: "yes", Level: 1}) {
+		t.Fatalf("expected the custom prompt to be answered, got %+v", m)
+	}
+}
+
+func TestLoop_BusyRuns(t *testing.T) {
+	b := New()
+	release := make(chan struct{})
+	err := b.RegisterAction("busy", func(ctx context.Context, io *Io) error {
+		<-release
+		_, err := io.Input.Text("Anything else?")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.RegisterAction("search", func(ctx context.Context, io *Io) error {
+		_, err := io.Input.Search("Find a user", func(ctx context.Context, query string) ([]SearchResult, error) {
+			if query == "slow" {
+				<-release
+			}
+			return nil, nil
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.RegisterAction("greet", func(ctx context.Context, io *Io) error {
+		_, err := io.Input.Text("What is your name?")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input := make(chan Message)
+	output := make(chan Message)
+	go b.Loop(ctx, input, output)
+
+	// an answer the handler isn't reading yet and a slow search don't hold up the other runs
+	input <- Message{Type: "start", Run: "busy", Data: "busy"}
+	input <- Message{Type: "input", Run: "busy", Data: "early"}
+	input <- Message{Type: "start", Run: "search", Data: "search"}
+	search := <-output
+	if search.Type != "searchInput" {
+		t.Fatalf("expected the search, got %+v", search)
+	}
+	input <- Message{Type: "query", Run: "search", Data: map[string]any{"id": search.Data.(*SearchInput).ID, "query": "slow"}}
+	input <- Message{Type: "start", Run: "greet", Data: "greet"}
+	if m := <-output; m.Type != "textInput" || m.Run != "greet" {
+		t.Fatalf("expected the greeting to start while the others are busy, got %+v", m)
+	}
+	input <- Message{Type: "cancel", Run: "greet"}
+	if m := <-output; m.Type != "cancelled" || m.Run != "greet" {
+		t.Fatalf("expected the greeting to be cancelled, got %+v", m)
+	}
+
+	close(release)
+	seen := map[string]string{}
+	for range 3 {
+		m := <-output
+		seen[m.Run+":"+m.Type] = m.Type
+	}
+	for _, expected := range []string{"search:queryResult", "busy:textInput", "busy:done"} {
+		if _, ok := seen[expected]; !ok {
+			t.Errorf("expected %s once the runs were released, got %v", expected, seen)
+		}
+	}
+}
@@ -2,54 +2,183 @@ package bff
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 )
 
-// Message represents a message with the backend
-type Message struct {
-	Type string `json:"type,omitempty"`
-	Data any    `json:"data,omitempty"`
-}
-
 // BFF represents the Backend for Frontend, which manages actions and pages
 type BFF struct {
-	actions map[string]*Action
-	mu      sync.RWMutex
+	actions    map[string]*Action
+	sessions   map[string]*Session
+	sessionTTL time.Duration
+	store      Store
+	runs       RunStore
+	runPolicy  RunPolicy
+	policy     Policy
+	uploads    *uploads
+	mu         sync.RWMutex
 }
 
+// Option configures a BFF instance
+type Option func(*BFF)
+
 // New creates a new BFF instance
-func New() *BFF {
-	return &BFF{
-		actions: make(map[string]*Action),
+func New(opts ...Option) *BFF {
+	b := &BFF{
+		actions:    make(map[string]*Action),
+		sessions:   make(map[string]*Session),
+		sessionTTL: DefaultSessionTTL,
+		policy:     RolePolicy,
+		runPolicy:  OwnRunsPolicy,
+		uploads:    newUploads(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// WithSessionTTL sets how long a session waits for a client to reconnect before the running action is abandoned
+func WithSessionTTL(ttl time.Duration) Option {
+	return func(b *BFF) {
+		b.sessionTTL = ttl
+	}
+}
+
+// WithPolicy replaces the RolePolicy deciding who can run which action
+func WithPolicy(policy Policy) Option {
+	return func(b *BFF) {
+		b.policy = policy
 	}
 }
 
 // RegisterAction adds a new action to the BFF
 func (b *BFF) RegisterAction(name string, handler HandlerFunc, opts ...ActionOption) error {
+	return b.register(NewAction(name, handler, opts...))
+}
+
+// RegisterActionWithResult adds a new action whose handler returns a value, see ResultHandlerFunc
+func (b *BFF) RegisterActionWithResult(name string, handler ResultHandlerFunc, opts ...ActionOption) error {
+	return b.register(NewActionWithResult(name, handler, opts...))
+}
+
+func (b *BFF) register(a *Action) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	if _, exists := b.actions[name]; exists {
+	if _, exists := b.actions[a.Name]; exists {
 		return ErrActionAlreadyExists
 	}
-
-	a := NewAction(name, handler, opts...)
 	b.actions[a.Slug] = a
 	return nil
 }
 
+// WithStore checkpoints every answer given in a session, so actions can pick up where they left off after a restart
+func WithStore(store Store) Option {
+	return func(b *BFF) {
+		b.store = store
+	}
+}
+
 // ExecuteAction runs the specified action
 func (b *BFF) ExecuteAction(ctx context.Context, name string, input <-chan Message, output chan<- Message) error {
+	_, err := b.ExecuteActionWithResult(ctx, name, input, output)
+	return err
+}
+
+// ExecuteActionWithResult runs the specified action and returns the value its handler returned, it is nil for
+// actions registered with RegisterAction
+func (b *BFF) ExecuteActionWithResult(ctx context.Context, name string, input <-chan Message, output chan<- Message) (any, error) {
+	io := NewIo(input, output)
+	io.uploads = b.uploads
+	return b.execute(ctx, name, io)
+}
+
+// execute runs the action with the given io, a checkpointed io has its checkpoint removed once the action is over
+func (b *BFF) execute(ctx context.Context, name string, io *Io) (any, error) {
 	b.mu.RLock()
 	action, exists := b.actions[name]
 	b.mu.RUnlock()
 	if !exists {
-		return ErrActionNotFound
+		return nil, ErrActionNotFound
+	}
+	if !b.Allowed(ctx, action) {
+		return nil, ErrForbidden
+	}
+	if action.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, action.timeout, ErrActionTimeout)
+		defer cancel()
+	}
+	io.ctx = ctx
+	if io.uploads != nil {
+		defer func() {
+			io.uploads.close(io.uploadIDs...)
+		}()
 	}
-	// make a nice little IO context we can give to the action to handle
-	io := NewIo(input, output)
 
-	return action.handler(ctx, io)
+	if io.checkpoint != nil {
+		defer func() {
+			// the run is over one way or another, there is nothing left to resume
+			err := io.store.Delete(context.Background(), io.checkpoint.Session, io.checkpoint.Run)
+			if err != nil {
+				slog.Error("failed to delete checkpoint", "session", io.checkpoint.Session, "run", io.checkpoint.Run, "err", err)
+			}
+		}()
+	}
+
+	if b.runs != nil {
+		b.openRun(ctx, action, io)
+	}
+	result, err := action.handler(ctx, io)
+	if err != nil && errors.Is(context.Cause(ctx), ErrActionTimeout) {
+		slog.Warn("action ran past its deadline", "action", action.Slug, "timeout", action.timeout, "err", err)
+		err = fmt.Errorf("%w of %s: %w", ErrActionTimeout, action.timeout, err)
+	}
+	if err == nil && result != nil && io.output != nil {
+		// a caller without an output only wants the result back
+		io.Display.Result(result)
+	}
+	if io.run != nil {
+		io.run.Finished = time.Now()
+		io.run.Result = result
+		if err != nil {
+			io.run.Error = err.Error()
+		}
+		io.saveRun()
+	}
+	return result, err
+}
+
+// Allowed checks the policy to see if the user in the context can run the action
+func (b *BFF) Allowed(ctx context.Context, action *Action) bool {
+	user, _ := UserFromContext(ctx)
+	if b.policy == nil {
+		return RolePolicy(ctx, user, action)
+	}
+	return b.policy(ctx, user, action)
+}
+
+// Action finds an action by its slug
+func (b *BFF) Action(slug string) (*Action, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	a, ok := b.actions[slug]
+	return a, ok
+}
+
+// ActionsFor returns the actions the user in the context is allowed to run
+func (b *BFF) ActionsFor(ctx context.Context) []*Action {
+	actions := b.GetActions()
+	allowed := actions[:0]
+	for _, a := range actions {
+		if b.Allowed(ctx, a) {
+			allowed = append(allowed, a)
+		}
+	}
+	return allowed
 }
 
 func (b *BFF) GetActions() []*Action {
@@ -63,30 +192,311 @@ func (b *BFF) GetActions() []*Action {
 	return actions
 }
 
+// Loop runs actions for a client until the context is done or the input is closed. Any number of actions can run at
+// once, every message about a run carries its ID. A "start" message starts a run with the run ID the client picked, or
+// one the loop makes up when it didn't pick one. Messages from the client without a run ID are for the run started
+// last.
+// Every message the loop sends has an ID. An answer in reply to anything but the prompt its run is waiting on is
+// rejected rather than given to the handler, I.E a late answer to an input that timed out. So is a message of a type
+// the loop doesn't know.
 func (b *BFF) Loop(ctx context.Context, input <-chan Message, output chan<- Message) {
-	// the application loop
+	b.loop(ctx, "", nil, nil, input, output)
+}
+
+// inboxSize is how many messages a run can have waiting before the loop turns more away, the loop never waits on a
+// handler to read them
+const inboxSize = 16
+
+// running is an action the loop has started, the handler runs on its own goroutine so the loop can keep answering
+// queries and run other actions while the handler is busy or waiting for input
+type running struct {
+	id   string
+	name string
+	io   *Io
+	// inbox is what the loop has for the handler, receive passes it on to the answers the handler reads
+	inbox   chan Message
+	answers chan Message
+	// sent is what the io sends, it is passed on to the loop output with the run ID by forward
+	sent chan Message
+	// cancel cancels the context the handler runs with, cancelled is set once it has been
+	cancel    context.CancelFunc
+	cancelled bool
+	// finished is closed when the handler returns, err is what it returned. flushed is closed after that, once
+	// everything the handler sent has been passed on.
+	finished chan struct{}
+	flushed  chan struct{}
+	err      error
+	// done is set by the loop once it has told the client the run is over
+	done bool
+
+	mu sync.Mutex
+	// asked is the ID of the last thing the handler sent that could be answered, prompt is the ID of the message it is
+	// waiting on an answer to. prompt is empty once the answer is in, unless it is turned down.
+	asked  string
+	prompt string
+	// showing is whether the element the handler is running waits for an answer, only forward uses it
+	showing bool
+}
+
+// loop is the application loop, the runs in resume are replayed from their checkpoints before waiting for anything else.
+// accepted is called with each answer the loop gives to a handler, before the handler can read it.
+func (b *BFF) loop(ctx context.Context, session string, resume []*Checkpoint, accepted func(Message), input <-chan Message, output chan<- Message) {
+	// runs are kept after they are done so their displays can still be queried, until the next run is started
+	runs := make(map[string]*running)
+	// latest is the run that messages without a run ID are for
+	var latest *running
+	over := make(chan *running)
+	quit := make(chan struct{})
+	// queries run user code, I.E the search of a SearchInput, so they are answered off the loop
+	var queries sync.WaitGroup
+	emit := func(m Message) {
+		m.ID = NewMessageID()
+		_ = send(ctx, output, m)
+	}
+	startRun := func(id, name string, checkpoint *Checkpoint) {
+		r := b.start(ctx, session, id, name, checkpoint)
+		go r.forward(ctx, output, over, quit)
+		runs[r.id] = r
+		latest = r
+	}
+	for _, checkpoint := range resume {
+		startRun(checkpoint.Run, checkpoint.Action, checkpoint)
+	}
+	defer func() {
+		close(quit)
+		for _, r := range runs {
+			r.stop()
+		}
+		queries.Wait()
+	}()
+
 	for {
 		select {
 		case <-ctx.Done():
 			slog.Debug("exiting bff loop with connection")
 			return
-		case v := <-input:
-			if v.Type == "start" {
-				// pass the input/output chanel to execute action
+		case r := <-over:
+			r.done = true
+			switch {
+			case r.err != nil && r.cancelled:
+				// the user gave up on it
+				emit(Message{Type: "cancelled", Run: r.id, Data: r.name})
+			case r.err != nil:
+				slog.Error("failed to execute action: ", "action", r.name, "run", r.id, "err", r.err)
+				emit(Message{Type: "error", Run: r.id, Data: r.err.Error()})
+			default:
+				// finished the action
+				emit(Message{Type: "done", Run: r.id, Data: r.name})
+			}
+		case v, ok := <-input:
+			if !ok {
+				slog.Debug("input closed, exiting bff loop")
+				return
+			}
+			r := latest
+			if v.Run != "" {
+				r = runs[v.Run]
+			}
+			switch v.Type {
+			case "ping":
+				// the client saying hello, nothing to do
+			case "start":
 				name, ok := v.Data.(string)
 				if !ok {
-					output <- Message{Type: "error", Data: "expected string"}
+					emit(Message{Type: "error", Run: v.Run, Data: "expected string"})
+					continue
+				}
+				if r != nil && v.Run != "" {
+					emit(Message{Type: "error", Run: v.Run, Data: "run " + v.Run + " already exists"})
 					continue
 				}
-				err := b.ExecuteAction(ctx, name, input, output)
-				if err != nil {
-					output <- Message{Type: "error", Data: err.Error()}
-					slog.Error("failed to execute action: ", "err", err)
-					return
+				for id, r := range runs {
+					if r.done {
+						delete(runs, id)
+					}
 				}
-				// finished the action
-				output <- Message{Type: "done", Data: name}
+				startRun(v.Run, name, nil)
+			case "query":
+				var io *Io
+				if r != nil {
+					io = r.io
+				}
+				queries.Add(1)
+				go func() {
+					defer queries.Done()
+					result := b.query(ctx, io, v)
+					result.Run = v.Run
+					result.InReplyTo = v.ID
+					emit(result)
+				}()
+			case "cancel":
+				if r != nil && !r.done {
+					r.abort()
+				}
+			case "input":
+				// answers are for the handler, as long as it is waiting on what the client is answering
+				waiting := r != nil && !r.done
+				if v.InReplyTo != "" && (!waiting || !r.claim(v.InReplyTo)) {
+					_ = send(ctx, output, Reject(v, ErrStaleAnswer))
+					continue
+				}
+				if waiting && !r.deliver(v, accepted) {
+					_ = send(ctx, output, Reject(v, ErrRunBusy))
+				}
+			default:
+				_ = send(ctx, output, Reject(v, ErrUnknownMessage))
 			}
 		}
 	}
 }
+
+// start runs the action in the background, the runs of a session are checkpointed when the BFF has a Store and the
+// answers in resume are replayed. A run without an ID is given one.
+func (b *BFF) start(ctx context.Context, session string, id string, name string, resume *Checkpoint) *running {
+	if id == "" {
+		id = newSessionID()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	r := &running{
+		id:       id,
+		name:     name,
+		inbox:    make(chan Message, inboxSize),
+		answers:  make(chan Message),
+		sent:     make(chan Message),
+		cancel:   cancel,
+		finished: make(chan struct{}),
+		flushed:  make(chan struct{}),
+	}
+	r.io = NewIo(r.answers, r.sent)
+	r.io.uploads = b.uploads
+	r.io.session = session
+	r.io.runID = id
+	r.io.announce = true
+	if session != "" && b.store != nil {
+		r.io.store = b.store
+		user, _ := UserFromContext(ctx)
+		r.io.checkpoint = &Checkpoint{Session: session, Run: id, Action: name, User: user}
+		if resume != nil {
+			r.io.checkpoint.Answers, r.io.checkpoint.TimedOut = resume.Answers, resume.TimedOut
+		}
+	}
+	go r.receive(ctx)
+	go func() {
+		defer close(r.finished)
+		defer cancel()
+		_, r.err = b.execute(ctx, name, r.io)
+	}()
+	return r
+}
+
+// receive passes the inbox on to the handler as it reads it, until the run is over or cancelled. The answers are closed
+// after that so whatever the handler is waiting on gives up.
+func (r *running) receive(ctx context.Context) {
+	defer close(r.answers)
+	for {
+		select {
+		case m := <-r.inbox:
+			select {
+			case r.answers <- m:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// forward passes on what the handler sends with the run ID, then lets the loop know the run is over
+func (r *running) forward(ctx context.Context, output chan<- Message, over chan<- *running, quit <-chan struct{}) {
+	defer close(r.flushed)
+	for {
+		select {
+		case m := <-r.sent:
+			if m.element != nil {
+				r.showing = isPrompt(m.element)
+				continue
+			}
+			m.Run = r.id
+			m.ID = NewMessageID()
+			r.track(&m)
+			_ = send(ctx, output, m)
+		case <-r.finished:
+			// the handler can't finish while it is still sending, so everything it sent has been passed on
+			select {
+			case over <- r:
+			case <-quit:
+			}
+			return
+		}
+	}
+}
+
+// track keeps up with the prompt the handler is waiting on from what it sends, the messages about the prompt are in
+// reply to it
+func (r *running) track(m *Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch m.Type {
+	case "loading":
+		// progress, there is nothing to answer
+	case "validationError":
+		// the answer was turned down, the prompt is waiting on another
+		m.InReplyTo = r.asked
+		r.prompt = r.asked
+	case "input", "inputTimeout":
+		// a replayed answer, or the handler giving up on the prompt
+		m.InReplyTo = r.asked
+		r.prompt = ""
+	default:
+		// the handler only waits on the last thing it sent, anything before it is done with. Displays aren't waited on
+		// at all.
+		r.asked, r.prompt = "", ""
+		if r.showing {
+			r.asked, r.prompt = m.ID, m.ID
+		}
+	}
+}
+
+// claim takes the answer to the prompt with the ID if the handler is waiting on it, so it is only answered once
+func (r *running) claim(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id != r.prompt {
+		return false
+	}
+	r.prompt = ""
+	return true
+}
+
+// deliver puts the message in the inbox of the handler without waiting on it, it is false when the inbox is full. A
+// cancelled run takes nothing more. Only the loop puts messages in the inbox, so one with room stays that way until
+// accepted has been called.
+func (r *running) deliver(m Message, accepted func(Message)) bool {
+	if r.cancelled {
+		return true
+	}
+	if len(r.inbox) == cap(r.inbox) {
+		return false
+	}
+	if accepted != nil {
+		accepted(m)
+	}
+	r.inbox <- m
+	return true
+}
+
+// abort cancels the context of the handler, receive closes its input so whatever it is waiting on gives up
+func (r *running) abort() {
+	if r.cancelled {
+		return
+	}
+	r.cancelled = true
+	r.cancel()
+}
+
+// stop unblocks a handler waiting for input and waits for everything it sent to be passed on
+func (r *running) stop() {
+	r.abort()
+	<-r.flushed
+}
@@ -0,0 +1,67 @@
+package bff
+
+import (
+	"context"
+	"fmt"
+)
+
+// ConfirmInput asks the user to confirm an action in a full screen dialog, the handler waits until they pick an answer
+type ConfirmInput struct {
+	Message  string `json:"message"`
+	HelpText string `json:"helpText,omitempty"`
+	// Danger styles the dialog for destructive actions
+	Danger       bool   `json:"danger,omitempty"`
+	ConfirmLabel string `json:"confirmLabel,omitempty"`
+	CancelLabel  string `json:"cancelLabel,omitempty"`
+}
+
+func (c *ConfirmInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "confirmInput", Data: c}, c.validate)
+}
+
+func (c *ConfirmInput) Prompt() bool { return true }
+
+// validate turns down anything but a yes or no, the dialog stays open for one
+func (c *ConfirmInput) validate(v any) error {
+	_, err := parseBoolean(v)
+	return err
+}
+
+// Confirm shows a dialog asking the user to confirm, it returns true when they do
+func (i *Input) Confirm(message string, options ...func(*ConfirmInput)) (bool, error) {
+	input := &ConfirmInput{Message: message}
+	for _, option := range options {
+		option(input)
+	}
+	v, err := i.io.AddToStack(input)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected boolean, got %T", v)
+	}
+	return b, nil
+}
+
+// WithConfirmHelpText explains what confirming will do
+func WithConfirmHelpText(text string) func(*ConfirmInput) {
+	return func(c *ConfirmInput) {
+		c.HelpText = text
+	}
+}
+
+// WithDanger styles the confirmation for a destructive action
+func WithDanger() func(*ConfirmInput) {
+	return func(c *ConfirmInput) {
+		c.Danger = true
+	}
+}
+
+// WithConfirmLabels changes the text of the confirm and cancel buttons
+func WithConfirmLabels(confirm string, cancel string) func(*ConfirmInput) {
+	return func(c *ConfirmInput) {
+		c.ConfirmLabel = confirm
+		c.CancelLabel = cancel
+	}
+}
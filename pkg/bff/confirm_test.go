@@ -0,0 +1,25 @@
+package bff
+
+import "testing"
+
+func TestConfirm(t *testing.T) {
+	input := make(chan Message, 2)
+	output := make(chan Message, 2)
+	io := NewIo(input, output)
+
+	input <- Message{Type: "input", Data: "yes please"}
+	input <- Message{Type: "input", Data: true}
+	confirmed, err := io.Input.Confirm("Delete the database?", WithDanger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !confirmed {
+		t.Error("expected the second answer to confirm")
+	}
+	if m := <-output; m.Type != "confirmInput" || !m.Data.(*ConfirmInput).Danger {
+		t.Errorf("expected a dangerous confirm, got %+v", m)
+	}
+	if m := <-output; m.Type != "validationError" || m.Data != "expected boolean, got string" {
+		t.Errorf("expected an answer that isn't a boolean to be turned down, got %+v", m)
+	}
+}
@@ -0,0 +1,300 @@
+package bff
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formField is a field of the struct a form fills in and the input that asks for it
+type formField struct {
+	name  string
+	index []int
+	input Executable
+	// options are the values of a select, its answer is the position of the option
+	options []string
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Form asks for a T with a form made from its exported fields, the answers are put in the fields. The field type
+// decides the input, a string is a text box, numbers are a number input taking whole numbers, a bool is a switch and a
+// time.Time is a date.
+// Tags configure the inputs, I.E
+//
+//	Email string    `bff:"label=Email,required,help=Where we send receipts,input=email"`
+//	Plan  string    `bff:"options=free|pro|enterprise"`
+//	Seats int       `bff:"min=1,max=100"`
+//	Notes string    `bff:"input=textarea,maxLength=500"`
+//	Start time.Time `bff:"input=time"`
+//	Internal string `bff:"-"`
+//
+// input can be email, url, textarea or richtext for strings, time for a time.Time and slider for numbers with a min
+// and max, and optionally a step. A string with options is a select, so is a []string where any number of the options
+// can be picked.
+func Form[T any](io *Io, label string) (T, error) {
+	var zero T
+	return form(io, label, zero, false)
+}
+
+// EditForm is Form filled in with the current values, the fields that aren't the zero value are the inputs defaults
+func EditForm[T any](io *Io, label string, current T) (T, error) {
+	return form(io, label, current, true)
+}
+
+func form[T any](io *Io, label string, current T, edit bool) (T, error) {
+	var result T
+	t := reflect.TypeOf(result)
+	if t == nil || t.Kind() != reflect.Struct {
+		return result, fmt.Errorf("forms are made from structs, not %T", result)
+	}
+	var values reflect.Value
+	if edit {
+		values = reflect.ValueOf(current)
+	}
+	fields, err := formFields(t, values)
+	if err != nil {
+		return result, err
+	}
+
+	var elements []Executable
+	if label != "" {
+		elements = append(elements, HeadingDisplay{Text: label, Level: 2})
+	}
+	offset := len(elements)
+	for _, f := range fields {
+		elements = append(elements, f.input)
+	}
+	answers, err := io.Group(elements...)
+	if err != nil {
+		return result, err
+	}
+
+	v := reflect.ValueOf(&result).Elem()
+	for i, f := range fields {
+		dst := fieldByIndex(v, f.index)
+		value := answers[offset+i]
+		switch picked := value.(type) {
+		case int:
+			if f.options != nil {
+				value = f.options[picked]
+			}
+		case []int:
+			chosen := make([]string, len(picked))
+			for n, p := range picked {
+				chosen[n] = f.options[p]
+			}
+			value = chosen
+		}
+		err = setField(dst, value)
+		if err != nil {
+			return result, fmt.Errorf("%s.%s: %w", t, f.name, err)
+		}
+	}
+	return result, nil
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex that makes the nil embedded pointers on the way
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// formFields makes the inputs for the fields of t, the fields of current are their defaults when it is valid
+func formFields(t reflect.Type, current reflect.Value) ([]formField, error) {
+	var fields []formField
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		tag := parseTag(f.Tag.Get("bff"))
+		if _, skip := tag["-"]; skip {
+			continue
+		}
+		var value reflect.Value
+		if current.IsValid() {
+			// a nil embedded pointer leaves its fields without defaults
+			value, _ = current.FieldByIndexErr(f.Index)
+		}
+		field, err := formInput(f, tag, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t, f.Name, err)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// formInput makes the input for a field from its type and tag, with the value as its default unless it is zero
+func formInput(f reflect.StructField, tag map[string]string, value reflect.Value) (formField, error) {
+	field := formField{name: f.Name, index: f.Index}
+	label := f.Name
+	if l, ok := tag["label"]; ok {
+		label = l
+	}
+	var options []InputOption
+	if help, ok := tag["help"]; ok {
+		options = append(options, WithHelpText(help))
+	}
+	if placeholder, ok := tag["placeholder"]; ok {
+		options = append(options, WithPlaceholder(placeholder))
+	}
+	if tag["required"] == "true" {
+		options = append(options, WithRequired(true))
+	}
+	var lengths []TextOption
+	for _, key := range []string{"minLength", "maxLength"} {
+		if s, ok := tag[key]; ok {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return field, fmt.Errorf("%s=%s is not a whole number", key, s)
+			}
+			if key == "minLength" {
+				lengths = append(lengths, WithMinLength(n))
+			} else {
+				lengths = append(lengths, WithMaxLength(n))
+			}
+		}
+	}
+	min, hasMin, err := tagFloat(tag, "min")
+	if err != nil {
+		return field, err
+	}
+	max, hasMax, err := tagFloat(tag, "max")
+	if err != nil {
+		return field, err
+	}
+	step, hasStep, err := tagFloat(tag, "step")
+	if err != nil {
+		return field, err
+	}
+
+	kind := tag["input"]
+	_, hasOptions := tag["options"]
+	number := isNumber(reflect.Zero(f.Type))
+	text := f.Type.Kind() == reflect.String && !hasOptions && (kind == "" || kind == "textarea" || kind == "richtext")
+	switch {
+	case len(lengths) > 0 && !text:
+		return field, fmt.Errorf("minLength and maxLength are for text inputs")
+	case (hasMin || hasMax) && !number:
+		return field, fmt.Errorf("min and max are for numbers")
+	case hasStep && kind != "slider":
+		return field, fmt.Errorf("step is for sliders")
+	}
+
+	hasDefault := value.IsValid() && !value.IsZero()
+	if hasOptions {
+		field.options = strings.Split(tag["options"], "|")
+		choices := make([]SelectChoice, len(field.options))
+		for i, option := range field.options {
+			choices[i] = SelectChoice{Label: option}
+			if hasDefault && f.Type.Kind() == reflect.String {
+				choices[i].Default = value.String() == option
+			}
+			if hasDefault && f.Type.Kind() == reflect.Slice {
+				for n := range value.Len() {
+					choices[i].Default = choices[i].Default || value.Index(n).String() == option
+				}
+			}
+		}
+		switch {
+		case f.Type.Kind() == reflect.String:
+			field.input = NewSelectSingleInput(label, choices, options...)
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.String:
+			field.input = NewSelectMultipleInput(label, choices, options...)
+		default:
+			return field, fmt.Errorf("options are picked in to a string or []string, not a %s", f.Type)
+		}
+		return field, nil
+	}
+
+	if hasDefault {
+		switch {
+		case f.Type == timeType:
+			options = append(options, WithDefault(value.Interface().(time.Time)))
+		case f.Type.Kind() == reflect.String:
+			options = append(options, WithDefault(value.String()))
+		case f.Type.Kind() == reflect.Bool:
+			options = append(options, WithDefault(value.Bool()))
+		case isNumber(value) && kind == "slider":
+			options = append(options, WithDefault(toFloat(value)))
+		case isNumber(value):
+			options = append(options, WithDefault(int(toFloat(value))))
+		}
+	}
+	switch {
+	case f.Type == timeType && kind == "time":
+		field.input = NewTimeInput(label, with[TimeOption](options)...)
+	case f.Type == timeType:
+		field.input = NewDateInput(label, with[DateOption](options)...)
+	case f.Type.Kind() == reflect.String:
+		switch kind {
+		case "":
+			field.input = NewTextInput(label, with(options, lengths...)...)
+		case "email":
+			field.input = NewEmailInput(label, options...)
+		case "url":
+			field.input = NewURLInput(label, options...)
+		case "textarea":
+			field.input = NewTextAreaInput(label, with(options, lengths...)...)
+		case "richtext":
+			field.input = NewRichTextInput(label, with(options, lengths...)...)
+		default:
+			return field, fmt.Errorf("input=%s is not an input for strings", kind)
+		}
+	case f.Type.Kind() == reflect.Bool:
+		field.input = NewBooleanInput(label, options...)
+	case number && kind == "slider":
+		if !hasMin || !hasMax {
+			return field, fmt.Errorf("a slider needs a min and max")
+		}
+		var steps []SliderOption
+		if hasStep {
+			steps = append(steps, WithStep(step))
+		}
+		field.input = NewSliderInput(label, min, max, with(options, steps...)...)
+	case number:
+		var limits []NumberOption
+		if hasMin {
+			limits = append(limits, WithMin(min))
+		}
+		if hasMax {
+			limits = append(limits, WithMax(max))
+		}
+		field.input = NewNumberInput(label, with(options, limits...)...)
+	default:
+		return field, fmt.Errorf("there is no input for a %s", f.Type)
+	}
+	return field, nil
+}
+
+// with is the shared options as options for one kind of input, followed by the options only that input takes
+func with[O any](shared []InputOption, only ...O) []O {
+	options := make([]O, 0, len(shared)+len(only))
+	for _, o := range shared {
+		options = append(options, any(o).(O))
+	}
+	return append(options, only...)
+}
+
+func tagFloat(tag map[string]string, key string) (float64, bool, error) {
+	s, ok := tag[key]
+	if !ok {
+		return 0, false, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s=%s is not a number", key, s)
+	}
+	return f, true, nil
+}
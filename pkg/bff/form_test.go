@@ -0,0 +1,122 @@
+package bff
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Audit struct {
+	Reason string `bff:"label=Why?,input=textarea"`
+}
+
+type accountForm struct {
+	Email    string    `bff:"label=Email,required,help=Where we send receipts,input=email"`
+	Seats    int       `bff:"min=1,max=100"`
+	Plan     string    `bff:"options=free|pro|enterprise"`
+	Addons   []string  `bff:"options=sso|audit"`
+	Trial    bool      `bff:"label=Start on a trial"`
+	Renews   time.Time `bff:"label=Renews on"`
+	Internal string    `bff:"-"`
+	*Audit
+}
+
+func TestForm(t *testing.T) {
+	input := make(chan Message, 1)
+	output := make(chan Message, 1)
+	io := NewIo(input, output)
+
+	input <- Message{Type: "input", Data: []any{"ops@example.com", "5", 2.0, []any{1.0}, true, "2025-01-31", "growing"}}
+	account, err := Form[accountForm](io, "New account")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := (<-output).Data.(Group)
+	var kinds []string
+	for _, e := range g.Elements {
+		kinds = append(kinds, reflect.TypeOf(e).String())
+	}
+	expected := []string{"bff.HeadingDisplay", "*bff.EmailInput", "*bff.NumberInput", "*bff.SelectInput", "*bff.SelectInput",
+		"*bff.BooleanInput", "*bff.DateInput", "*bff.TextAreaInput"}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("expected inputs %v, got %v", expected, kinds)
+	}
+	email := g.Elements[1].(*EmailInput)
+	if email.Label != "Email" || !email.Required || email.HelpText != "Where we send receipts" {
+		t.Errorf("expected the tag to configure the email, got %+v", email.InputBase)
+	}
+
+	renews := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	if account.Email != "ops@example.com" || account.Seats != 5 || account.Plan != "enterprise" ||
+		!reflect.DeepEqual(account.Addons, []string{"audit"}) || !account.Trial || !account.Renews.Equal(renews) {
+		t.Errorf("unexpected account %+v", account)
+	}
+	if account.Audit == nil || account.Audit.Reason != "growing" {
+		t.Errorf("expected the embedded struct to be filled, got %+v", account.Audit)
+	}
+}
+
+func TestForm_Invalid(t *testing.T) {
+	io := NewIo(make(chan Message), make(chan Message))
+
+	_, err := Form[string](io, "Nope")
+	if err == nil {
+		t.Error("expected a form of a string to be refused")
+	}
+	_, err = Form[struct{ Ch chan int }](io, "Nope")
+	if err == nil {
+		t.Error("expected a field without an input to be refused")
+	}
+	_, err = Form[struct {
+		Seats int `bff:"min=few"`
+	}](io, "Nope")
+	if err == nil {
+		t.Error("expected a min that isn't a number to be refused")
+	}
+	_, err = Form[struct {
+		Volume float64 `bff:"input=slider"`
+	}](io, "Nope")
+	if err == nil {
+		t.Error("expected a slider without a range to be refused")
+	}
+	_, err = Form[struct {
+		Seats int `bff:"maxLength=3"`
+	}](io, "Nope")
+	if err == nil {
+		t.Error("expected a length on a number to be refused")
+	}
+}
+
+func TestEditForm(t *testing.T) {
+	input := make(chan Message, 1)
+	output := make(chan Message, 1)
+	io := NewIo(input, output)
+
+	current := accountForm{Email: "ops@example.com", Seats: 5, Plan: "pro", Addons: []string{"sso"}}
+	input <- Message{Type: "input", Data: []any{"ops@example.com", 6.0, 1.0, []any{0.0}, false, "", ""}}
+	updated, err := EditForm(io, "Edit account", current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := (<-output).Data.(Group)
+	if email := g.Elements[1].(*EmailInput); email.DefaultValue != "ops@example.com" {
+		t.Errorf("expected the current email as the default, got %v", email.DefaultValue)
+	}
+	if seats := g.Elements[2].(*NumberInput); seats.DefaultValue != 5 {
+		t.Errorf("expected the current seats as the default, got %v", seats.DefaultValue)
+	}
+	plan := g.Elements[3].(*SelectInput)
+	if plan.Options[0].Default || !plan.Options[1].Default {
+		t.Errorf("expected the current plan to be chosen, got %+v", plan.Options)
+	}
+	if addons := g.Elements[4].(*SelectInput); !addons.Options[0].Default || addons.Options[1].Default {
+		t.Errorf("expected the current addons to be chosen, got %+v", addons.Options)
+	}
+	if renews := g.Elements[6].(*DateInput); renews.DefaultValue != nil {
+		t.Errorf("expected a zero date to have no default, got %v", renews.DefaultValue)
+	}
+	if updated.Seats != 6 {
+		t.Errorf("expected the seats to be updated, got %+v", updated)
+	}
+}
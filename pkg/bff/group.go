@@ -0,0 +1,188 @@
+package bff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldErrors are what was wrong with the answers to a group, by the position of the input in the group
+type FieldErrors map[int]string
+
+func (f FieldErrors) Error() string {
+	positions := make([]int, 0, len(f))
+	for p := range f {
+		positions = append(positions, p)
+	}
+	sort.Ints(positions)
+	problems := make([]string, len(positions))
+	for i, p := range positions {
+		problems[i] = fmt.Sprintf("element %d: %s", p, f[p])
+	}
+	return strings.Join(problems, ", ")
+}
+
+// Group shows its elements together as one form, the inputs in it are answered with a single submit. The answer is a
+// list with the answer to each input, in order.
+type Group struct {
+	Elements []Executable
+}
+
+func (g Group) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	if len(g.inputs()) == 0 {
+		return nil, send(ctx, output, Message{Type: "group", Data: g})
+	}
+	return ask(ctx, input, output, Message{Type: "group", Data: g}, g.validate)
+}
+
+func (g Group) Prompt() bool { return len(g.inputs()) > 0 }
+
+// MarshalJSON sends each element the way it would be sent on its own
+func (g Group) MarshalJSON() ([]byte, error) {
+	elements := make([]Message, len(g.Elements))
+	for i, e := range g.Elements {
+		elements[i] = render(e)
+	}
+	return json.Marshal(struct {
+		Elements []Message `json:"elements"`
+	}{elements})
+}
+
+// render is the message the element shows itself with, it is executed without anything to answer it to find out
+func render(element Executable) Message {
+	output := make(chan Message, 1)
+	closed := make(chan Message)
+	close(closed)
+	_, _ = element.Execute(context.Background(), closed, output)
+	select {
+	case m := <-output:
+		return m
+	default:
+		return Message{}
+	}
+}
+
+// inputs are the positions of the elements that need answering, only elements that say they are prompts are answered
+// in a group
+func (g Group) inputs() []int {
+	var inputs []int
+	for i, e := range g.Elements {
+		if p, ok := e.(Prompter); ok && p.Prompt() {
+			inputs = append(inputs, i)
+		}
+	}
+	return inputs
+}
+
+func (g Group) validate(v any) error {
+	_, err := g.parse(v)
+	return err
+}
+
+// parse checks the answer to every input and returns a value for every element, displays are nil
+func (g Group) parse(v any) ([]any, error) {
+	var answers []any
+	err := decodeData(v, &answers)
+	if err != nil {
+		return nil, fmt.Errorf("expected a list of answers, got %T", v)
+	}
+	inputs := g.inputs()
+	if len(answers) != len(inputs) {
+		return nil, fmt.Errorf("expected %d answers, got %d", len(inputs), len(answers))
+	}
+	values := make([]any, len(g.Elements))
+	problems := make(FieldErrors)
+	for n, i := range inputs {
+		f, ok := g.Elements[i].(field)
+		if !ok || f.base().parse == nil {
+			return nil, fmt.Errorf("%T can't be part of a group", g.Elements[i])
+		}
+		value, err := f.base().parse(answers[n])
+		if err != nil {
+			problems[i] = err.Error()
+			continue
+		}
+		values[i] = value
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return values, nil
+}
+
+// Group shows the elements as one form and waits for the inputs in it to be answered together. Inputs are best made
+// with their constructor, I.E NewTextInput, so they can take options. Displays can be used as they are. The result has a value for every element, the
+// answer for inputs, I.E a string for a TextInput or a time.Time for a DateInput, and nil for displays.
+func (io *Io) Group(elements ...Executable) ([]any, error) {
+	g := Group{Elements: elements}
+	for _, e := range elements {
+		if p, ok := e.(Prompter); !ok || !p.Prompt() {
+			continue
+		}
+		constrain(e)
+		f, ok := e.(field)
+		if !ok || f.base().parse == nil {
+			return nil, fmt.Errorf("%T can't be part of a group, make it with its New...Input constructor", e)
+		}
+		if f.base().err != nil {
+			return nil, f.base().err
+		}
+	}
+	v, err := io.AddToStack(g)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.inputs()) == 0 {
+		return make([]any, len(elements)), nil
+	}
+	return g.parse(v)
+}
+
+// GroupInto asks for the elements as a Group and puts the answers in the exported fields of the struct dst points to,
+// the first answer in the first field and so on. Displays don't take up a field.
+func (io *Io) GroupInto(dst any, elements ...Executable) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected a pointer to a struct, got %T", dst)
+	}
+	s := v.Elem()
+	var fields []int
+	for i := 0; i < s.NumField(); i++ {
+		if s.Type().Field(i).IsExported() {
+			fields = append(fields, i)
+		}
+	}
+	inputs := Group{Elements: elements}.inputs()
+	if len(inputs) != len(fields) {
+		return fmt.Errorf("%d inputs can't fill the %d fields of %s", len(inputs), len(fields), s.Type())
+	}
+
+	values, err := io.Group(elements...)
+	if err != nil {
+		return err
+	}
+	for n, i := range inputs {
+		err = setField(s.Field(fields[n]), values[i])
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", s.Type(), s.Type().Field(fields[n]).Name, err)
+		}
+	}
+	return nil
+}
+
+// setField sets the field to the answer, numbers are converted to the type of the field
+func setField(field reflect.Value, value any) error {
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Type().AssignableTo(field.Type()):
+		field.Set(v)
+	case isNumber(v) && isNumber(field):
+		field.Set(v.Convert(field.Type()))
+	default:
+		return fmt.Errorf("can't set a %s to a %s", field.Type(), v.Type())
+	}
+	return nil
+}
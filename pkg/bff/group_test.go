@@ -0,0 +1,121 @@
+package bff
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIo_Group(t *testing.T) {
+	input := make(chan Message)
+	output := make(chan Message)
+	io := NewIo(input, output)
+
+	type result struct {
+		values []any
+		err    error
+	}
+	done := make(chan result)
+	go func() {
+		values, err := io.Group(
+			HeadingDisplay{Text: "Update user details", Level: 2},
+			NewTextInput("Name", WithRequired(true)),
+			NewNumberInput("Seats", WithMin(1)),
+			NewBooleanInput("Reset password?"),
+		)
+		done <- result{values, err}
+	}()
+
+	m := <-output
+	if m.Type != "group" {
+		t.Fatalf("expected a group, got %s", m.Type)
+	}
+	b, err := json.Marshal(m.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent struct {
+		Elements []struct {
+			Type string `json:"type"`
+		} `json:"elements"`
+	}
+	err = json.Unmarshal(b, &sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, e := range sent.Elements {
+		types = append(types, e.Type)
+	}
+	if len(types) != 4 || types[0] != "display" || types[1] != "textInput" || types[3] != "booleanInput" {
+		t.Errorf("expected each element as it would be sent on its own, got %v", types)
+	}
+
+	input <- Message{Type: "input", Data: []any{"", "0", true}}
+	m = <-output
+	fields, ok := m.Data.(FieldErrors)
+	if m.Type != "validationError" || !ok || fields[1] != ErrRequired.Error() || fields[2] != "must be at least 1" {
+		t.Errorf("expected the name and seats to be wrong, got %+v", m)
+	}
+
+	input <- Message{Type: "input", Data: []any{"alice", "3", true}}
+	r := <-done
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if r.values[0] != nil || r.values[1] != "alice" || r.values[2] != 3 || r.values[3] != true {
+		t.Errorf("unexpected values %#v", r.values)
+	}
+}
+
+func TestIo_GroupInto(t *testing.T) {
+	input := make(chan Message, 1)
+	output := make(chan Message, 1)
+	io := NewIo(input, output)
+
+	var settings struct {
+		Name   string
+		Seats  int64
+		Admin  bool
+		secret string
+	}
+	input <- Message{Type: "input", Data: []any{"alice", "3", true}}
+	err := io.GroupInto(&settings,
+		HeadingDisplay{Text: "Settings"},
+		NewTextInput("Name"),
+		NewNumberInput("Seats"),
+		NewBooleanInput("Admin"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-output
+	if settings.Name != "alice" || settings.Seats != 3 || !settings.Admin {
+		t.Errorf("unexpected settings %+v", settings)
+	}
+
+	err = io.GroupInto(&settings, NewTextInput("Name"))
+	if err == nil {
+		t.Error("expected too few inputs for the fields to be an error")
+	}
+}
+
+func TestIo_Group_Inputs(t *testing.T) {
+	input := make(chan Message, 2)
+	output := make(chan Message, 2)
+	io := NewIo(input, output)
+	// an input made without its constructor is held to its fields
+	input <- Message{Type: "input", Data: []any{"al"}}
+	input <- Message{Type: "input", Data: []any{"alice"}}
+	values, err := io.Group(&TextInput{InputBase: InputBase{Label: "Name"}, MinLength: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-output
+	if m := <-output; m.Type != "validationError" || values[0] != "alice" {
+		t.Errorf("expected the short name to be turned down, got %+v and %v", m, values)
+	}
+	_, err = io.Group(&ConfirmInput{Message: "Sure?"})
+	if err == nil {
+		t.Error("expected a confirm to be refused")
+	}
+}
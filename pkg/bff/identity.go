@@ -0,0 +1,265 @@
+package bff
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrIdentityNotConfirmed = errors.New("identity not confirmed")
+
+// DefaultIdentityAttempts is how many codes the user can try before ConfirmIdentity gives up
+const DefaultIdentityAttempts = 3
+
+// IdentityVerifier proves the user is who they say they are with a code, I.E from an authenticator app or sent to
+// their email. The context is the context of the action, so a verifier can look up the user it belongs to.
+type IdentityVerifier interface {
+	// Challenge starts a verification, I.E by sending the user a code, and returns instructions to show the user
+	Challenge(ctx context.Context) (string, error)
+	// Verify checks the code the user entered
+	Verify(ctx context.Context, code string) (bool, error)
+}
+
+// Notifier sends a message to someone, I.E by email or chat
+type Notifier interface {
+	Notify(ctx context.Context, recipient string, message string) error
+}
+
+// ConfirmIdentityInput asks the user for a code that proves who they are, the answer is whether the code was right
+type ConfirmIdentityInput struct {
+	Message      string `json:"message"`
+	Instructions string `json:"instructions,omitempty"`
+	// Error explains what went wrong with the last attempt
+	Error string `json:"error,omitempty"`
+
+	verifier IdentityVerifier
+	attempts int
+}
+
+func (c *ConfirmIdentityInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	instructions, err := c.verifier.Challenge(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("starting identity challenge: %w", err)
+	}
+	c.Instructions = instructions
+	code, err := ask(ctx, input, output, Message{Type: "confirmIdentityInput", Data: c}, c.validate)
+	if err != nil {
+		return nil, err
+	}
+	return c.verifier.Verify(ctx, strings.TrimSpace(code.(string)))
+}
+
+// validate turns down anything but a string, the prompt stays open for a code
+func (c *ConfirmIdentityInput) validate(v any) error {
+	if _, ok := v.(string); !ok {
+		return fmt.Errorf("expected string, got %T", v)
+	}
+	return nil
+}
+
+func (c *ConfirmIdentityInput) Prompt() bool { return true }
+
+// replay shows the prompt as it was answered, codes are only good once so the verification can't be run again
+func (c *ConfirmIdentityInput) replay(ctx context.Context, answer any, output chan<- Message) (any, error) {
+	err := send(ctx, output, Message{Type: "confirmIdentityInput", Data: c})
+	if err != nil {
+		return nil, err
+	}
+	err = send(ctx, output, Message{Type: "input", Data: answer})
+	if err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// ConfirmIdentity asks the user to prove who they are again before the handler carries on, it returns
+// ErrIdentityNotConfirmed when they run out of attempts
+func (i *Input) ConfirmIdentity(message string, verifier IdentityVerifier, options ...func(*ConfirmIdentityInput)) error {
+	config := &ConfirmIdentityInput{attempts: DefaultIdentityAttempts}
+	for _, option := range options {
+		option(config)
+	}
+	for attempt := 1; attempt <= config.attempts; attempt++ {
+		input := &ConfirmIdentityInput{Message: message, verifier: verifier}
+		if attempt > 1 {
+			input.Error = fmt.Sprintf("That code was not right, %d attempts left", config.attempts-attempt+1)
+		}
+		v, err := i.io.AddToStack(input)
+		if err != nil {
+			return err
+		}
+		if verified, _ := v.(bool); verified {
+			return nil
+		}
+	}
+	return ErrIdentityNotConfirmed
+}
+
+// WithIdentityAttempts sets how many codes the user can try
+func WithIdentityAttempts(attempts int) func(*ConfirmIdentityInput) {
+	return func(c *ConfirmIdentityInput) {
+		c.attempts = attempts
+	}
+}
+
+// DefaultTOTPDigits and DefaultTOTPPeriod are what authenticator apps use unless told otherwise
+const (
+	DefaultTOTPDigits = 6
+	DefaultTOTPPeriod = 30 * time.Second
+)
+
+// TOTPVerifier checks time based one time passwords (RFC 6238) from an authenticator app
+type TOTPVerifier struct {
+	Secret []byte
+	// Digits is DefaultTOTPDigits unless it is between 1 and 9, Period is DefaultTOTPPeriod unless it is a second or more
+	Digits int
+	Period time.Duration
+	// Skew is how many periods either side of now are accepted, to allow for clocks that disagree
+	Skew int
+
+	now  func() time.Time
+	mu   sync.Mutex
+	used uint64
+}
+
+// NewTOTPVerifier creates a verifier for the base32 secret the authenticator app was set up with, using the usual
+// six digits every thirty seconds
+func NewTOTPVerifier(secret string) (*TOTPVerifier, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return nil, fmt.Errorf("decoding totp secret: %w", err)
+	}
+	return &TOTPVerifier{Secret: key, Digits: DefaultTOTPDigits, Period: DefaultTOTPPeriod, Skew: 1}, nil
+}
+
+func (t *TOTPVerifier) Challenge(ctx context.Context) (string, error) {
+	return "Enter the code from your authenticator app", nil
+}
+
+func (t *TOTPVerifier) Verify(ctx context.Context, code string) (bool, error) {
+	counter := t.counter(t.clock())
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for skew := -t.Skew; skew <= t.Skew; skew++ {
+		c := counter + uint64(skew)
+		if subtle.ConstantTimeCompare([]byte(t.code(c)), []byte(code)) != 1 {
+			continue
+		}
+		// a code can't be used twice, someone could be reading over the user's shoulder
+		if c <= t.used {
+			return false, nil
+		}
+		t.used = c
+		return true, nil
+	}
+	return false, nil
+}
+
+func (t *TOTPVerifier) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// Code is the code an authenticator app shows at the given time
+func (t *TOTPVerifier) Code(at time.Time) string {
+	return t.code(t.counter(at))
+}
+
+// counter is the number of periods since the epoch at the given time
+func (t *TOTPVerifier) counter(at time.Time) uint64 {
+	period := t.Period
+	if period < time.Second {
+		period = DefaultTOTPPeriod
+	}
+	return uint64(at.Unix()) / uint64(period/time.Second)
+}
+
+func (t *TOTPVerifier) code(counter uint64) string {
+	mac := hmac.New(sha1.New, t.Secret)
+	_ = binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	digits := t.Digits
+	if digits < 1 || digits > 9 {
+		// any more wouldn't fit in the 31 bits the code is made from
+		digits = DefaultTOTPDigits
+	}
+	mod := uint32(1)
+	for range digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// DefaultCodeTTL is how long a code sent by a CodeVerifier is good for
+const DefaultCodeTTL = 10 * time.Minute
+
+// CodeVerifier sends a one time code to the user with a Notifier and checks they entered it
+type CodeVerifier struct {
+	Notifier  Notifier
+	Recipient string
+	TTL       time.Duration
+
+	now     func() time.Time
+	mu      sync.Mutex
+	code    string
+	expires time.Time
+}
+
+// NewCodeVerifier sends codes to the recipient, I.E the user's email address, with the notifier
+func NewCodeVerifier(notifier Notifier, recipient string) *CodeVerifier {
+	return &CodeVerifier{Notifier: notifier, Recipient: recipient, TTL: DefaultCodeTTL}
+}
+
+func (c *CodeVerifier) Challenge(ctx context.Context) (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+	c.mu.Lock()
+	c.code = code
+	c.expires = c.clock().Add(c.TTL)
+	c.mu.Unlock()
+
+	err = c.Notifier.Notify(ctx, c.Recipient, fmt.Sprintf("Your verification code is %s", code))
+	if err != nil {
+		return "", fmt.Errorf("sending verification code: %w", err)
+	}
+	return fmt.Sprintf("We sent a code to %s", c.Recipient), nil
+}
+
+func (c *CodeVerifier) Verify(ctx context.Context, code string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.code == "" || c.clock().After(c.expires) {
+		return false, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(c.code), []byte(code)) != 1 {
+		return false, nil
+	}
+	// codes are good for one go
+	c.code = ""
+	return true, nil
+}
+
+func (c *CodeVerifier) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,121 @@
+package bff
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPVerifier(t *testing.T) {
+	// the SHA1 test vector from RFC 6238, "12345678901234567890" in base32
+	totp, err := NewTOTPVerifier("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	totp.Digits = 8
+	if code := totp.Code(time.Unix(59, 0)); code != "94287082" {
+		t.Errorf("expected 94287082, got %s", code)
+	}
+	if code := totp.Code(time.Unix(1111111109, 0)); code != "07081804" {
+		t.Errorf("expected 07081804, got %s", code)
+	}
+
+	now := time.Unix(1111111109, 0)
+	totp.now = func() time.Time { return now }
+	ctx := context.Background()
+	ok, err := totp.Verify(ctx, "00000000")
+	if err != nil || ok {
+		t.Errorf("expected a wrong code to fail, got %v %v", ok, err)
+	}
+	// a code from the last period is still good, the clocks might not agree
+	ok, err = totp.Verify(ctx, totp.Code(now.Add(-30*time.Second)))
+	if err != nil || !ok {
+		t.Errorf("expected the previous code to pass, got %v %v", ok, err)
+	}
+	ok, _ = totp.Verify(ctx, totp.Code(now.Add(-30*time.Second)))
+	if ok {
+		t.Error("expected a code to only work once")
+	}
+
+	// a verifier made without the constructor uses the usual digits and period
+	bare := &TOTPVerifier{Secret: totp.Secret}
+	if code := bare.Code(time.Unix(59, 0)); code != "287082" {
+		t.Errorf("expected 287082, got %s", code)
+	}
+	bare.now = totp.now
+	if ok, err := bare.Verify(ctx, bare.Code(now)); err != nil || !ok {
+		t.Errorf("expected the current code to pass, got %v %v", ok, err)
+	}
+}
+
+type notification struct {
+	recipient string
+	message   string
+}
+
+type testNotifier struct {
+	sent []notification
+}
+
+func (n *testNotifier) Notify(ctx context.Context, recipient string, message string) error {
+	n.sent = append(n.sent, notification{recipient, message})
+	return nil
+}
+
+func (n *testNotifier) lastCode() string {
+	m := n.sent[len(n.sent)-1].message
+	return m[strings.LastIndex(m, " ")+1:]
+}
+
+func TestConfirmIdentity(t *testing.T) {
+	notifier := &testNotifier{}
+	verifier := NewCodeVerifier(notifier, "ops@example.com")
+
+	input := make(chan Message)
+	output := make(chan Message, 1)
+	io := NewIo(input, output)
+	done := make(chan error)
+	go func() {
+		done <- io.Input.ConfirmIdentity("Confirm it's you before deleting the database", verifier)
+	}()
+
+	m := <-output
+	prompt := m.Data.(*ConfirmIdentityInput)
+	if prompt.Instructions != "We sent a code to ops@example.com" || prompt.Error != "" {
+		t.Errorf("unexpected prompt %+v", prompt)
+	}
+	// an answer that isn't a code keeps the prompt open rather than failing the action
+	input <- Message{Type: "input", Data: 123456.0}
+	if m := <-output; m.Type != "validationError" {
+		t.Fatalf("expected a validation error, got %+v", m)
+	}
+	input <- Message{Type: "input", Data: "not the code"}
+
+	m = <-output
+	prompt = m.Data.(*ConfirmIdentityInput)
+	if prompt.Error == "" {
+		t.Error("expected the second attempt to explain the first one failed")
+	}
+	if len(notifier.sent) != 2 || notifier.sent[1].recipient != "ops@example.com" {
+		t.Errorf("expected a new code to be sent for the second attempt, got %+v", notifier.sent)
+	}
+	input <- Message{Type: "input", Data: " " + notifier.lastCode() + " "}
+	if err := <-done; err != nil {
+		t.Errorf("expected the identity to be confirmed, got %v", err)
+	}
+
+	t.Run("gives up after too many attempts", func(t *testing.T) {
+		go func() {
+			done <- io.Input.ConfirmIdentity("Confirm it's you", verifier, WithIdentityAttempts(2))
+		}()
+		for range 2 {
+			<-output
+			input <- Message{Type: "input", Data: "nope"}
+		}
+		if err := <-done; !errors.Is(err, ErrIdentityNotConfirmed) {
+			t.Errorf("expected ErrIdentityNotConfirmed, got %v", err)
+		}
+	})
+}
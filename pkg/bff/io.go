@@ -1,8 +1,14 @@
 package bff
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sync"
 	"time"
 )
 
@@ -10,8 +16,38 @@ type Io struct {
 	stack   []Executable
 	Display Display
 	Input   Input
+	Loading Loading
 	input   <-chan Message
 	output  chan<- Message
+
+	// ctx is the context of the action this io belongs to
+	ctx context.Context
+	// checkpoint records the answers given so far when the BFF has a Store, it is nil otherwise
+	checkpoint *Checkpoint
+	store      Store
+
+	// queriers are the elements on the stack the client can query, guarded by mu as the loop reads them
+	mu       sync.Mutex
+	queriers map[string]Querier
+
+	// session is the session the io is running in, if any
+	session string
+	// run is the record of this run when the BFF has a RunStore, it is nil otherwise. It is saved to runs as it goes.
+	run  *Run
+	runs RunStore
+	// runID is the ID the loop knows the run by, the record of the run is saved with it
+	runID string
+
+	// loading is the last progress sent by Loading
+	loading LoadingState
+
+	// uploads is where file inputs have their files sent, the uploads made by this io are thrown away with it
+	uploads   *uploads
+	uploadIDs []string
+
+	// announce is set when the loop reads the output, each element is sent ahead of what it sends so the loop knows
+	// whether it is waiting on an answer
+	announce bool
 }
 
 func NewIo(input <-chan Message, output chan<- Message) *Io {
@@ -19,11 +55,13 @@ func NewIo(input <-chan Message, output chan<- Message) *Io {
 		stack:  make([]Executable, 0),
 		input:  input,
 		output: output,
+		ctx:    context.Background(),
 	}
 	display := Display{io}
 	i := Input{io}
 	io.Display = display
 	io.Input = i
+	io.Loading = Loading{io}
 	return io
 }
 
@@ -36,10 +74,87 @@ type Display struct {
 type Input struct {
 	io *Io
 }
+
+// Executable is an element of the stack, Execute sends it to the client on the output and waits on the input for the
+// answer when it needs one. It must give up with the error of the context once the context is done, I.E when the run
+// is cancelled or the client is gone for good.
 type Executable interface {
+	Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error)
+}
+
+// LegacyExecutable is an element written before Execute took a context, Adapt turns it in to an Executable
+type LegacyExecutable interface {
 	Execute(input <-chan Message, output chan<- Message) (any, error)
 }
 
+// Adapt makes a LegacyExecutable an Executable. The element can't be told the context is done, so it is run on its own
+// goroutine and left behind when the context is done first, it returns once its input is closed.
+func Adapt(element LegacyExecutable) Executable {
+	return adapted{element}
+}
+
+type adapted struct {
+	element LegacyExecutable
+}
+
+func (a adapted) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	type result struct {
+		v   any
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := a.element.Execute(input, output)
+		done <- result{v, err}
+	}()
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (a adapted) Prompt() bool {
+	p, ok := a.element.(Prompter)
+	return !ok || p.Prompt()
+}
+
+func (a adapted) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.element)
+}
+
+// unwrap is the element itself when it was adapted
+func unwrap(element Executable) any {
+	if a, ok := element.(adapted); ok {
+		return a.element
+	}
+	return element
+}
+
+// send puts the message on the output, unless the context is done first
+func send(ctx context.Context, output chan<- Message, m Message) error {
+	select {
+	case output <- m:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Prompter is an element that says whether it waits for the user to answer, I.E a TextInput does and a HeadingDisplay
+// doesn't. The elements of this package all do.
+type Prompter interface {
+	Prompt() bool
+}
+
+// isPrompt is true for elements that wait for the user to answer. An element that doesn't say is taken to, so a custom
+// input written before Prompter can still be answered.
+func isPrompt(element Executable) bool {
+	p, ok := element.(Prompter)
+	return !ok || p.Prompt()
+}
+
 // need to support
 // DONE:
 // - Group: Combines multiple I/O method calls into a single form.
@@ -50,18 +165,18 @@ type Executable interface {
 // - input.slider requests a number value within a range
 // - input.date requests a date value
 // - input.textArea requests a text area value
+// - input.selectTable requests a selection from a table of options
+// - input.search search for arbitrary results using a search box
+// - input.selectSingle Prompts the app user to select a single value from a set of provided values.
+// - input.selectMultiple Prompts the app user to select any number of values from a set of provided values.
+// - input.confirm requests confirmation of an action using a full screen dialog box
+// - input.confirmIdentity (multi factor with the users email)
+// - input.file requests a file value
 
 // TODO:
 // - input.richText requests a rich text value
 // - input.url requests a URL value
 // - input.time requests a date with time value
-// - input.file requests a file value
-
-// - input.confirm requests confirmation of an action using a full screen dialog box
-// - input.confirmIdentity (multi factor with the users email)
-// - input.search search for arbitrary results using a search box
-// - input.selectTable requests a selection from a table of options
-// - input.selectSingle Prompts the app user to select a single value from a set of provided values.
 
 // InputBase defines everything that all inputs have in common
 type InputBase struct {
@@ -69,9 +184,23 @@ type InputBase struct {
 	HelpText    string `json:"helpText,omitempty"`
 	Placeholder string `json:"placeholder,omitempty"`
 	Required    bool   `json:"required,omitempty"`
+	// DefaultValue is what the input is filled in with to begin with, see WithDefault
+	DefaultValue any `json:"defaultValue,omitempty"`
+
+	// validators are added by WithValidator, parse is built from them and the constraints of the input by its
+	// constructor. err is set when the validators don't fit the input. See setup.
+	validators []any
+	parse      func(any) (any, error)
+	err        error
+	// initial is the value given to WithDefault, the constructor checks it and sets DefaultValue
+	initial any
+	// minLength and maxLength are set by WithMinLength and WithMaxLength for the text inputs
+	minLength, maxLength int
+	// timeout is set by WithTimeout
+	timeout time.Duration
 }
 
-type InputOption func(*InputBase)
+func (b *InputBase) Prompt() bool { return true }
 
 // TextInput is a text box input
 type TextInput struct {
@@ -80,41 +209,42 @@ type TextInput struct {
 	MaxLength int `json:"maxLength,omitempty"`
 }
 
-func (h *TextInput) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "textInput", Data: h}
-	m := <-input
-	if m.Type != "input" {
-		return nil, fmt.Errorf("expected input, got %s", m.Type)
-	}
-	return m.Data, nil
+func (h *TextInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "textInput", Data: h}, h.validate)
+}
+
+func (h *TextInput) constrain() {
+	setup(&h.InputBase, parseString(&h.InputBase, h.MinLength, h.MaxLength))
 }
 
 type BooleanInput struct {
 	InputBase
 }
 
-func (h *BooleanInput) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "booleanInput", Data: h}
-	m := <-input
-	if m.Type != "input" {
-		return nil, fmt.Errorf("expected input, got %s", m.Type)
-	}
-	return m.Data, nil
+func (h *BooleanInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "booleanInput", Data: h}, h.validate)
+}
+
+func (h *BooleanInput) constrain() {
+	setup(&h.InputBase, parseBoolean)
 }
 
 type NumberInput struct {
 	InputBase
+	// Min and Max are only checked when they aren't 0, or were set with WithMin and WithMax
 	Min float64
 	Max float64
+
+	// min and max are set by WithMin and WithMax, so a Min or Max of 0 is checked too
+	min, max *float64
 }
 
-func (h *NumberInput) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "numberInput", Data: h}
-	m := <-input
-	if m.Type != "input" {
-		return nil, fmt.Errorf("expected input, got %s", m.Type)
-	}
-	return m.Data, nil
+func (h *NumberInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "numberInput", Data: h}, h.validate)
+}
+
+func (h *NumberInput) constrain() {
+	setup(&h.InputBase, parseNumber(h))
 }
 
 //---------------
@@ -128,11 +258,11 @@ func (h *NumberInput) Execute(input <-chan Message, output chan<- Message) (any,
 // - display.metadata Displays a series of label/value pairs in a variety of layout options.
 // - display.code Displays a block of code to the action user.
 // - display.html Displays rendered HTML to the action user.
+// - display.table Displays tabular data.
+// - display.object Displays an object of nested data to the action user.
 
 // TODO:
 // - display.grid  Displays data in a grid layout https://interval.com/docs/io-methods/display-grid
-// - display.object Displays an object of nested data to the action user.
-// - display.table Displays tabular data.
 // - display.video Displays a video to the action user. One of url or buffer must be provided.
 
 type Image struct {
@@ -141,30 +271,45 @@ type Image struct {
 	Size string `json:"size,omitempty"`
 }
 
-func (c Image) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "image", Data: c}
-	return nil, nil
+func (c Image) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return nil, send(ctx, output, Message{Type: "image", Data: c})
 }
 
+func (c Image) Prompt() bool { return false }
+
 type HeadingDisplay struct {
 	Text  string `json:"text,omitempty"`
 	Level int    `json:"level,omitempty"`
 }
 
-func (h HeadingDisplay) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "display", Data: h}
-	return nil, nil
+func (h HeadingDisplay) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return nil, send(ctx, output, Message{Type: "display", Data: h})
 }
 
+func (h HeadingDisplay) Prompt() bool { return false }
+
 type MarkdownDisplay struct {
 	Content string `json:"content"`
 }
 
-func (m MarkdownDisplay) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "markdown", Data: m}
-	return nil, nil
+func (m MarkdownDisplay) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return nil, send(ctx, output, Message{Type: "markdown", Data: m})
+}
+
+func (m MarkdownDisplay) Prompt() bool { return false }
+
+// ObjectDisplay shows nested data, I.E a struct or a map, as a tree of labels and values
+type ObjectDisplay struct {
+	Label string `json:"label,omitempty"`
+	Value any    `json:"value"`
+}
+
+func (o ObjectDisplay) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return nil, send(ctx, output, Message{Type: "object", Data: o})
 }
 
+func (o ObjectDisplay) Prompt() bool { return false }
+
 // LinkDisplay represents a button-styled action link
 type LinkDisplay struct {
 	Text string `json:"text"`
@@ -172,32 +317,35 @@ type LinkDisplay struct {
 	Type string `json:"type,omitempty"` // "default", "primary", "danger", etc.
 }
 
-func (l LinkDisplay) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "link", Data: l}
-	return nil, nil
+func (l LinkDisplay) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return nil, send(ctx, output, Message{Type: "link", Data: l})
 }
 
+func (l LinkDisplay) Prompt() bool { return false }
+
 // HtmlDisplay represents rendered HTML content
 type HtmlDisplay struct {
 	Content string `json:"content"`
 }
 
-func (h HtmlDisplay) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "html", Data: h}
-	return nil, nil
+func (h HtmlDisplay) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return nil, send(ctx, output, Message{Type: "html", Data: h})
 }
 
+func (h HtmlDisplay) Prompt() bool { return false }
+
 // CodeDisplay represents a block of code (already implemented, shown here for completeness)
 type CodeDisplay struct {
 	Code     string `json:"code"`
 	Language string `json:"language,omitempty"`
 }
 
-func (c CodeDisplay) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "code", Data: c}
-	return nil, nil
+func (c CodeDisplay) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return nil, send(ctx, output, Message{Type: "code", Data: c})
 }
 
+func (c CodeDisplay) Prompt() bool { return false }
+
 // MetadataItem represents a single label/value pair in the metadata display
 type MetadataItem struct {
 	Label string `json:"label"`
@@ -210,11 +358,12 @@ type MetadataDisplay struct {
 	Layout string         `json:"layout,omitempty"` // "default", "card", "table", etc.
 }
 
-func (m MetadataDisplay) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "metadata", Data: m}
-	return nil, nil
+func (m MetadataDisplay) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return nil, send(ctx, output, Message{Type: "metadata", Data: m})
 }
 
+func (m MetadataDisplay) Prompt() bool { return false }
+
 func (d *Display) Link(text string, url string, options ...func(*LinkDisplay)) {
 	link := &LinkDisplay{Text: text, Url: url}
 	for _, option := range options {
@@ -249,20 +398,143 @@ func WithMetadataLayout(layout string) func(*MetadataDisplay) {
 	}
 }
 
-// Group combines multiple I/O method calls
-type Group struct {
-	Elements []Executable
+// AddToStack adds the element to the stack and executes it -- returning the result of the execution. Once the context
+// of the action is cancelled, I.E the user cancelled the run, it returns the error of the context instead.
+func (io *Io) AddToStack(element Executable) (any, error) {
+	return io.addToStack(element, 0)
+}
+
+// addToStack is AddToStack where the element gives up with ErrInputTimeout when it isn't answered within the timeout,
+// a timeout of 0 waits for as long as the action runs
+func (io *Io) addToStack(element Executable, timeout time.Duration) (any, error) {
+	if err := io.ctx.Err(); err != nil {
+		return nil, err
+	}
+	constrain(element)
+	io.stack = append(io.stack, element)
+	if io.announce {
+		if err := send(io.ctx, io.output, Message{element: element}); err != nil {
+			return nil, err
+		}
+	}
+	if io.checkpoint == nil {
+		v, err := io.execute(element, timeout)
+		if err == nil {
+			io.record(element, v)
+		}
+		return v, err
+	}
+
+	position := len(io.stack) - 1
+	if slices.Contains(io.checkpoint.TimedOut, position) {
+		// it timed out before the restart, the handler gets the same outcome as it did then
+		err := send(io.ctx, io.output, render(element))
+		if err == nil {
+			err = send(io.ctx, io.output, Message{Type: "inputTimeout"})
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrInputTimeout
+	}
+	if position < len(io.checkpoint.Answers) {
+		answer := io.checkpoint.Answers[position]
+		if replayable(element, answer) {
+			v, err := io.replay(element, answer)
+			if err == nil {
+				io.record(element, v)
+			}
+			return v, err
+		}
+		// the answers after it were given to a handler that went another way, the user is asked again from here on
+		io.checkpoint.Answers = io.checkpoint.Answers[:position]
+		io.checkpoint.TimedOut = slices.DeleteFunc(io.checkpoint.TimedOut, func(p int) bool { return p >= position })
+	}
+	v, err := io.execute(element, timeout)
+	if errors.Is(err, ErrInputTimeout) {
+		// the handler can carry on after a timeout, the replay has to know there is no answer for this one
+		io.checkpoint.Answers = append(io.checkpoint.Answers, nil)
+		io.checkpoint.TimedOut = append(io.checkpoint.TimedOut, position)
+		io.save()
+		return nil, err
+	}
+	if err != nil {
+		return v, err
+	}
+	io.record(element, v)
+	io.checkpoint.Answers = append(io.checkpoint.Answers, v)
+	io.save()
+	return v, nil
+}
+
+func (io *Io) save() {
+	err := io.store.Save(io.ctx, io.checkpoint)
+	if err != nil {
+		// the action can carry on, it just won't survive a restart
+		slog.Error("failed to save checkpoint", "session", io.checkpoint.Session, "err", err)
+	}
 }
 
-func (g Group) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "group", Data: g}
-	return nil, nil
+// execute runs the element with the context of the action, cut short by the timeout when there is one
+func (io *Io) execute(element Executable, timeout time.Duration) (any, error) {
+	ctx := io.ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(io.ctx, timeout)
+		defer cancel()
+	}
+	v, err := element.Execute(ctx, io.input, io.output)
+	if err != nil && io.ctx.Err() != nil {
+		// I.E the input was closed because the run was cancelled
+		return nil, io.ctx.Err()
+	}
+	if err != nil && ctx.Err() != nil {
+		// the client closes the prompt, so an answer that comes in too late isn't taken for the next one
+		err = send(io.ctx, io.output, Message{Type: "inputTimeout"})
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrInputTimeout
+	}
+	return v, err
 }
 
-// AddToStack adds the element to the stack and executes it -- returning the result of the execution
-func (io *Io) AddToStack(element Executable) (any, error) {
-	io.stack = append(io.stack, element)
-	return element.Execute(io.input, io.output)
+// replayer is implemented by elements that can't just be executed again with their old answer when a checkpoint is
+// replayed, I.E because the answer was checked against something that only works once
+type replayer interface {
+	replay(ctx context.Context, answer any, output chan<- Message) (any, error)
+}
+
+// checker is an element that can check an answer without being shown, I.E an input made by its constructor
+type checker interface {
+	validate(v any) error
+}
+
+// replayable is false when the answer given before the restart no longer passes the checks of the element, I.E the
+// handler has changed since or the file it names was uploaded to an upload that is gone
+func replayable(element Executable, answer any) bool {
+	if _, ok := element.(replayer); ok {
+		return true
+	}
+	c, ok := element.(checker)
+	return !ok || c.validate(answer) == nil
+}
+
+// replay executes the element again with the answer it was given before the restart
+func (io *Io) replay(element Executable, answer any) (any, error) {
+	if r, ok := element.(replayer); ok {
+		return r.replay(io.ctx, answer, io.output)
+	}
+	recorded := make(chan Message, 1)
+	recorded <- Message{Type: "input", Data: answer}
+	// closed so an answer that no longer passes validation fails the replay rather than waiting for another
+	close(recorded)
+	v, err := element.Execute(io.ctx, recorded, io.output)
+	if err == nil && len(recorded) == 0 {
+		// it was a prompt, show the client what the answer was
+		err = send(io.ctx, io.output, Message{Type: "input", Data: answer})
+	}
+	return v, err
 }
 
 func (d *Display) Group(elements ...Executable) {
@@ -284,77 +556,151 @@ func (d *Display) Markdown(content string) {
 	_, _ = d.io.AddToStack(MarkdownDisplay{Content: content})
 }
 
-func (i *Input) Text(label string, options ...func(*TextInput)) (string, error) {
-	input := &TextInput{InputBase: InputBase{Label: label}}
-	for _, option := range options {
-		option(input)
-	}
-	v, err := i.io.AddToStack(input)
+// Table displays a slice of structs or maps, the client pages and sorts through it. See NewTable for the columns.
+func (d *Display) Table(label string, rows any, options ...TableOption) error {
+	table, err := NewTable(label, rows, options...)
 	if err != nil {
-		return "", err
+		return err
 	}
-	s, ok := v.(string)
-	if !ok {
-		return "", fmt.Errorf("expected string, got %T", v)
+	table.ID = d.io.register(table)
+	_, err = d.io.AddToStack(table)
+	return err
+}
+
+func (d *Display) Object(label string, value any) {
+	_, _ = d.io.AddToStack(ObjectDisplay{Label: label, Value: value})
+}
+
+// Result shows the value an action returned, a list of structs or maps as a table and anything else as an object
+func (d *Display) Result(value any) {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		table, err := NewTable("Result", value)
+		if err == nil {
+			table.ID = d.io.register(table)
+			_, _ = d.io.AddToStack(table)
+			return
+		}
 	}
-	return s, nil
+	d.Object("Result", value)
 }
 
-func (i *Input) Boolean(label string, options ...func(*InputBase)) (bool, error) {
+// NewTextInput makes a text box, ask for it on its own with Input.Text or along with other inputs with Io.Group
+func NewTextInput(label string, options ...TextOption) *TextInput {
+	input := &TextInput{InputBase: InputBase{Label: label}}
+	for _, option := range options {
+		option.applyText(&input.InputBase)
+	}
+	input.MinLength, input.MaxLength = input.minLength, input.maxLength
+	input.constrain()
+	setDefault[string](&input.InputBase)
+	return input
+}
+
+func (i *Input) Text(label string, options ...TextOption) (string, error) {
+	return prompt[string](i.io, NewTextInput(label, options...))
+}
+
+func NewBooleanInput(label string, options ...InputOption) *BooleanInput {
 	input := &BooleanInput{InputBase: InputBase{Label: label}}
 	for _, option := range options {
 		option(&input.InputBase)
 	}
-	v, err := i.io.AddToStack(input)
-	if err != nil {
-		return false, err
-	}
-	b, ok := v.(bool)
-	if !ok {
-		return false, fmt.Errorf("expected boolean, got %T", v)
-	}
-	return b, nil
+	input.constrain()
+	setDefault[bool](&input.InputBase)
+	return input
+}
+
+func (i *Input) Boolean(label string, options ...InputOption) (bool, error) {
+	return prompt[bool](i.io, NewBooleanInput(label, options...))
 }
 
-func (i *Input) Number(label string, options ...func(*NumberInput)) (int, error) {
+func NewNumberInput(label string, options ...NumberOption) *NumberInput {
 	input := &NumberInput{InputBase: InputBase{Label: label}}
 	for _, option := range options {
-		option(input)
+		option.applyNumber(input)
 	}
-	v, err := i.io.AddToStack(input)
-	if err != nil {
-		return 0, err
+	if input.min != nil {
+		input.Min = *input.min
 	}
-	n, err := strconv.Atoi(v.(string))
-	if err != nil {
-		return 0, fmt.Errorf("expected number, got %T: %w", v, err)
+	if input.max != nil {
+		input.Max = *input.max
 	}
-	return n, nil
+	input.constrain()
+	setDefault[int](&input.InputBase)
+	return input
+}
+
+func (i *Input) Number(label string, options ...NumberOption) (int, error) {
+	return prompt[int](i.io, NewNumberInput(label, options...))
 }
 
 // Implement other input methods similarly...
 
 // WithHelpText is an option function to set the help text of an input
-func WithHelpText(text string) func(*InputBase) {
+func WithHelpText(text string) InputOption {
 	return func(i *InputBase) {
 		i.HelpText = text
 	}
 }
 
 // WithPlaceholder is an option function to set the placeholder of an input
-func WithPlaceholder(placeholder string) func(*InputBase) {
+func WithPlaceholder(placeholder string) InputOption {
 	return func(i *InputBase) {
 		i.Placeholder = placeholder
 	}
 }
 
 // WithRequired is an option function to set the required status of an input
-func WithRequired(required bool) func(*InputBase) {
+func WithRequired(required bool) InputOption {
 	return func(i *InputBase) {
 		i.Required = required
 	}
 }
 
+// ErrInputTimeout is returned by an input given WithTimeout when nobody answers it in time
+var ErrInputTimeout = errors.New("input was not answered in time")
+
+// WithTimeout gives up on the input when it isn't answered within d, the input returns ErrInputTimeout or the value
+// given to WithDefault when there is one
+func WithTimeout(d time.Duration) InputOption {
+	return func(i *InputBase) {
+		i.timeout = d
+	}
+}
+
+// WithDefault fills the input in to begin with, I.E with the current value of the record being edited. T is the type
+// the input returns, a string for Text, an int for Number, a time.Time for Date and Time, the position of the choice
+// for SelectSingle or the positions of the choices for SelectMultiple. The SelectSingle and SelectMultiple functions
+// take the value of the option, or a slice of them, instead. The input returns an error when it is asked for with a
+// default of another type.
+func WithDefault[T any](value T) InputOption {
+	return func(i *InputBase) {
+		i.initial = value
+	}
+}
+
+// defaultOf is the value given to WithDefault, the input is broken when it isn't a T
+func defaultOf[T any](base *InputBase) (T, bool) {
+	var zero T
+	if base.initial == nil {
+		return zero, false
+	}
+	d, ok := base.initial.(T)
+	if !ok {
+		base.err = fmt.Errorf("default for %q is a %T, the input needs a %T", base.Label, base.initial, zero)
+		return zero, false
+	}
+	return d, true
+}
+
+// setDefault sends the default to the client as it is
+func setDefault[T any](base *InputBase) {
+	if d, ok := defaultOf[T](base); ok {
+		base.DefaultValue = d
+	}
+}
+
 // EmailInput represents an email input field
 type EmailInput struct {
 	InputBase
@@ -368,6 +714,12 @@ type SliderInput struct {
 	Step float64 `json:"step,omitempty"`
 }
 
+// dateLayout and timeLayout are how dates and times of day are sent to and from the client
+const (
+	dateLayout = "2006-01-02"
+	timeLayout = "15:04"
+)
+
 // DateInput represents a date input field
 type DateInput struct {
 	InputBase
@@ -397,196 +749,217 @@ type TimeInput struct {
 	Max string `json:"max,omitempty"` // HH:mm format
 }
 
-// FileInput represents a file input field
+// FileInput represents a file input field, the files are sent to the upload named by UploadID rather than over the
+// socket
 type FileInput struct {
 	InputBase
 	Accept   string `json:"accept,omitempty"` // MIME types or file extensions
 	Multiple bool   `json:"multiple,omitempty"`
+	MaxSize  int64  `json:"maxSize,omitempty"` // largest file in bytes
+	// MaxFiles is the most files that can be uploaded when Multiple is set, DefaultMaxFiles when it is 0
+	MaxFiles int    `json:"maxFiles,omitempty"`
+	UploadID string `json:"uploadId"`
 }
 
 // Implement Execute method for each new input type
-func (e *EmailInput) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "emailInput", Data: e}
-	m := <-input
-	if m.Type != "input" {
-		return nil, fmt.Errorf("expected input, got %s", m.Type)
-	}
-	return m.Data, nil
+func (e *EmailInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "emailInput", Data: e}, e.validate)
 }
 
-func (s *SliderInput) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "sliderInput", Data: s}
-	m := <-input
-	if m.Type != "input" {
-		return nil, fmt.Errorf("expected input, got %s", m.Type)
-	}
-	return m.Data, nil
+func (e *EmailInput) constrain() {
+	setup(&e.InputBase, parseEmail(&e.InputBase))
 }
 
-func (d *DateInput) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "dateInput", Data: d}
-	m := <-input
-	if m.Type != "input" {
-		return nil, fmt.Errorf("expected input, got %s", m.Type)
-	}
-	return m.Data, nil
+func (s *SliderInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "sliderInput", Data: s}, s.validate)
 }
 
-func (r *RichTextInput) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "richTextInput", Data: r}
-	m := <-input
-	if m.Type != "input" {
-		return nil, fmt.Errorf("expected input, got %s", m.Type)
-	}
-	return m.Data, nil
+func (s *SliderInput) constrain() {
+	setup(&s.InputBase, parseSlider(s))
 }
 
-func (r *TextAreaInput) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "textAreaInput", Data: r}
-	m := <-input
-	if m.Type != "input" {
-		return nil, fmt.Errorf("expected input, got %s", m.Type)
-	}
-	return m.Data, nil
+func (d *DateInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "dateInput", Data: d}, d.validate)
 }
 
-func (u *URLInput) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "urlInput", Data: u}
-	m := <-input
-	if m.Type != "input" {
-		return nil, fmt.Errorf("expected input, got %s", m.Type)
-	}
-	return m.Data, nil
+func (d *DateInput) constrain() {
+	setup(&d.InputBase, parseTime(&d.InputBase, dateLayout, d.Min, d.Max))
 }
 
-func (t *TimeInput) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "timeInput", Data: t}
-	m := <-input
-	if m.Type != "input" {
-		return nil, fmt.Errorf("expected input, got %s", m.Type)
-	}
-	return m.Data, nil
+func (r *RichTextInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "richTextInput", Data: r}, r.validate)
+}
+
+func (r *RichTextInput) constrain() {
+	setup(&r.InputBase, parseString(&r.InputBase, r.minLength, r.maxLength))
+}
+
+func (r *TextAreaInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "textAreaInput", Data: r}, r.validate)
+}
+
+func (r *TextAreaInput) constrain() {
+	setup(&r.InputBase, parseString(&r.InputBase, r.minLength, r.maxLength))
+}
+
+func (u *URLInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "urlInput", Data: u}, u.validate)
+}
+
+func (u *URLInput) constrain() {
+	setup(&u.InputBase, parseURL(&u.InputBase))
 }
 
-func (f *FileInput) Execute(input <-chan Message, output chan<- Message) (any, error) {
-	output <- Message{Type: "fileInput", Data: f}
-	m := <-input
-	if m.Type != "input" {
-		return nil, fmt.Errorf("expected input, got %s", m.Type)
+func (t *TimeInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "timeInput", Data: t}, t.validate)
+}
+
+func (t *TimeInput) constrain() {
+	setup(&t.InputBase, parseTime(&t.InputBase, timeLayout, t.Min, t.Max))
+}
+
+func (f *FileInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "fileInput", Data: f}, f.validate)
+}
+
+// maxFiles is how many files can be uploaded to the input
+func (f *FileInput) maxFiles() int {
+	switch {
+	case !f.Multiple:
+		return 1
+	case f.MaxFiles <= 0:
+		return DefaultMaxFiles
 	}
-	return m.Data, nil
+	return f.MaxFiles
 }
 
 // Add new methods to the Input struct
-func (i *Input) Email(label string, options ...InputOption) (string, error) {
+func NewEmailInput(label string, options ...InputOption) *EmailInput {
 	input := &EmailInput{InputBase: InputBase{Label: label}}
 	for _, option := range options {
 		option(&input.InputBase)
 	}
-	v, err := i.io.AddToStack(input)
-	if err != nil {
-		return "", err
-	}
-	return v.(string), nil
+	input.constrain()
+	setDefault[string](&input.InputBase)
+	return input
 }
 
-func (i *Input) Slider(label string, min, max float64, options ...InputOption) (float64, error) {
+func (i *Input) Email(label string, options ...InputOption) (string, error) {
+	return prompt[string](i.io, NewEmailInput(label, options...))
+}
+
+func NewSliderInput(label string, min, max float64, options ...SliderOption) *SliderInput {
 	input := &SliderInput{InputBase: InputBase{Label: label}, Min: min, Max: max}
 	for _, option := range options {
-		option(&input.InputBase)
+		option.applySlider(input)
 	}
-	v, err := i.io.AddToStack(input)
-	if err != nil {
-		return 0, err
-	}
-	return v.(float64), nil
+	input.constrain()
+	setDefault[float64](&input.InputBase)
+	return input
+}
+
+func (i *Input) Slider(label string, min, max float64, options ...SliderOption) (float64, error) {
+	return prompt[float64](i.io, NewSliderInput(label, min, max, options...))
 }
 
-func (i *Input) Date(label string, options ...InputOption) (time.Time, error) {
+func NewDateInput(label string, options ...DateOption) *DateInput {
 	input := &DateInput{InputBase: InputBase{Label: label}}
 	for _, option := range options {
-		option(&input.InputBase)
+		option.applyDate(input)
 	}
-	v, err := i.io.AddToStack(input)
-	if err != nil {
-		return time.Time{}, err
+	input.constrain()
+	if d, ok := defaultOf[time.Time](&input.InputBase); ok {
+		input.DefaultValue = d.Format(dateLayout)
 	}
-	return time.Parse("2006-01-02", v.(string))
+	return input
+}
+
+func (i *Input) Date(label string, options ...DateOption) (time.Time, error) {
+	return prompt[time.Time](i.io, NewDateInput(label, options...))
 }
 
-func (i *Input) RichText(label string, options ...InputOption) (string, error) {
+func NewRichTextInput(label string, options ...TextOption) *RichTextInput {
 	input := &RichTextInput{InputBase: InputBase{Label: label}}
 	for _, option := range options {
-		option(&input.InputBase)
+		option.applyText(&input.InputBase)
 	}
-	v, err := i.io.AddToStack(input)
-	if err != nil {
-		return "", err
+	input.constrain()
+	if d, ok := defaultOf[string](&input.InputBase); ok {
+		input.InitialValue = d
 	}
-	return v.(string), nil
+	return input
 }
 
-func (i *Input) TextArea(label string, options ...InputOption) (string, error) {
+func (i *Input) RichText(label string, options ...TextOption) (string, error) {
+	return prompt[string](i.io, NewRichTextInput(label, options...))
+}
+
+func NewTextAreaInput(label string, options ...TextOption) *TextAreaInput {
 	input := &TextAreaInput{InputBase: InputBase{Label: label}}
 	for _, option := range options {
-		option(&input.InputBase)
+		option.applyText(&input.InputBase)
 	}
-	v, err := i.io.AddToStack(input)
-	if err != nil {
-		return "", err
+	input.constrain()
+	if d, ok := defaultOf[string](&input.InputBase); ok {
+		input.InitialValue = d
 	}
-	return v.(string), nil
+	return input
 }
 
-func (i *Input) URL(label string, options ...func(*InputBase)) (string, error) {
+func (i *Input) TextArea(label string, options ...TextOption) (string, error) {
+	return prompt[string](i.io, NewTextAreaInput(label, options...))
+}
+
+func NewURLInput(label string, options ...InputOption) *URLInput {
 	input := &URLInput{InputBase: InputBase{Label: label}}
 	for _, option := range options {
 		option(&input.InputBase)
 	}
-	v, err := i.io.AddToStack(input)
-	if err != nil {
-		return "", err
-	}
-	return v.(string), nil
+	input.constrain()
+	setDefault[string](&input.InputBase)
+	return input
 }
 
-func (i *Input) Time(label string, options ...InputOption) (time.Time, error) {
+func (i *Input) URL(label string, options ...InputOption) (string, error) {
+	return prompt[string](i.io, NewURLInput(label, options...))
+}
+
+func NewTimeInput(label string, options ...TimeOption) *TimeInput {
 	input := &TimeInput{InputBase: InputBase{Label: label}}
 	for _, option := range options {
-		option(&input.InputBase)
+		option.applyTime(input)
 	}
-	v, err := i.io.AddToStack(input)
-	if err != nil {
-		return time.Time{}, err
+	input.constrain()
+	if d, ok := defaultOf[time.Time](&input.InputBase); ok {
+		input.DefaultValue = d.Format(timeLayout)
 	}
-	// parse
-	return time.Parse("15:04", v.(string))
+	return input
+}
+
+func (i *Input) Time(label string, options ...TimeOption) (time.Time, error) {
+	return prompt[time.Time](i.io, NewTimeInput(label, options...))
 }
 
-func (i *Input) File(label string, options ...InputOption) ([]string, error) {
-	input := &FileInput{InputBase: InputBase{Label: label}}
+// File asks the user to upload files, they can be read until the action is over
+func (i *Input) File(label string, options ...FileOption) ([]UploadedFile, error) {
+	input := &FileInput{InputBase: InputBase{Label: label}, MaxSize: DefaultMaxFileSize, MaxFiles: DefaultMaxFiles}
 	for _, option := range options {
-		option(&input.InputBase)
+		option.applyFile(input)
 	}
-	v, err := i.io.AddToStack(input)
-	if err != nil {
-		return nil, err
+	if i.io.uploads == nil {
+		return nil, fmt.Errorf("file inputs need the action to be run by a BFF")
 	}
-	arr, ok := v.([]any)
-	if !ok {
-		return nil, fmt.Errorf("expected []string, got %T", v)
+	user, _ := UserFromContext(i.io.ctx)
+	input.UploadID = i.io.uploads.open(input, user)
+	i.io.uploadIDs = append(i.io.uploadIDs, input.UploadID)
+
+	// the files are checked before the prompt closes, so a missing file can be uploaded without starting over
+	input.parse = func(v any) (any, error) {
+		return i.io.uploads.check(input.UploadID, v)
 	}
-	files := make([]string, 0, len(arr))
-	for _, r := range arr {
-		str, ok := r.(string)
-		if !ok {
-			return nil, fmt.Errorf("expected string, got %T", r)
-		}
-		files = append(files, str)
+	v, err := i.io.addToStack(input, input.timeout)
+	if err != nil {
+		return nil, err
 	}
-
-	return files, nil
+	return i.io.uploads.resolve(input.UploadID, v)
 }
-
-// TODO the option system needs to be extended here we really want to make it so that you can further configure things
-// 	like Files with the same stuff that inputOption can do but also aditional file specific things
@@ -0,0 +1,183 @@
+package bff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithDefault(t *testing.T) {
+	renews := time.Date(2025, 1, 31, 9, 30, 0, 0, time.UTC)
+	for name, c := range map[string]struct {
+		input    *InputBase
+		expected any
+	}{
+		"text":    {&NewTextInput("Name", WithDefault("alice")).InputBase, "alice"},
+		"number":  {&NewNumberInput("Seats", WithDefault(3)).InputBase, 3},
+		"boolean": {&NewBooleanInput("Admin", WithDefault(true)).InputBase, true},
+		"slider":  {&NewSliderInput("Volume", 0, 10, WithDefault(2.5)).InputBase, 2.5},
+		"date":    {&NewDateInput("Renews", WithDefault(renews)).InputBase, "2025-01-31"},
+		"time":    {&NewTimeInput("At", WithDefault(renews)).InputBase, "09:30"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if c.input.err != nil {
+				t.Fatal(c.input.err)
+			}
+			if c.input.DefaultValue != c.expected {
+				t.Errorf("expected the default %v, got %v", c.expected, c.input.DefaultValue)
+			}
+		})
+	}
+
+	if area := NewTextAreaInput("Notes", WithDefault("hi")); area.InitialValue != "hi" {
+		t.Errorf("expected a text area to start with its default, got %q", area.InitialValue)
+	}
+
+	io := NewIo(make(chan Message), make(chan Message))
+	_, err := io.Input.Number("Seats", WithDefault("three"))
+	if err == nil {
+		t.Error("expected a string default to be refused by a number input")
+	}
+	_, err = io.Input.Number("Seats", WithValidator(func(s string) error { return nil }))
+	if err == nil {
+		t.Error("expected a string validator to be refused by a number input")
+	}
+}
+
+func TestInput_TypedOptions(t *testing.T) {
+	slider := NewSliderInput("Volume", 0, 10, WithStep(0.5), WithHelpText("How loud"))
+	if slider.Step != 0.5 || slider.HelpText != "How loud" {
+		t.Errorf("expected the step and help text to be set, got %+v", slider)
+	}
+	if err := slider.validate(2.5); err != nil {
+		t.Errorf("expected 2.5 to be a step, got %v", err)
+	}
+	if err := slider.validate(2.3); err == nil {
+		t.Error("expected 2.3 to be between steps")
+	}
+
+	date := NewDateInput("Renews", WithMinDate(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)), WithRequired(true))
+	if date.Min != "2025-01-01" || !date.Required {
+		t.Errorf("expected the min and required to be set, got %+v", date)
+	}
+	if err := date.validate("2024-12-31"); err == nil {
+		t.Error("expected a day before the min to be refused")
+	}
+
+	at := NewTimeInput("At", WithMaxTime(time.Date(0, 1, 1, 17, 0, 0, 0, time.UTC)))
+	if at.Max != "17:00" {
+		t.Errorf("expected the max to be set, got %q", at.Max)
+	}
+	if err := at.validate("17:30"); err == nil {
+		t.Error("expected a time after the max to be refused")
+	}
+
+	file := &FileInput{}
+	for _, option := range []FileOption{WithAccept("image/*"), WithMultiple(), WithPlaceholder("Drop it here")} {
+		option.applyFile(file)
+	}
+	if file.Accept != "image/*" || !file.Multiple || file.Placeholder != "Drop it here" {
+		t.Errorf("expected the file options to be set, got %+v", file)
+	}
+}
+
+// colorInput is a custom component written before Execute took a context
+type colorInput struct {
+	Label string `json:"label"`
+}
+
+func (c colorInput) Execute(input <-chan Message, output chan<- Message) (any, error) {
+	output <- Message{Type: "colorInput", Data: c}
+	m := <-input
+	return m.Data, nil
+}
+
+func TestAdapt(t *testing.T) {
+	input := make(chan Message, 1)
+	output := make(chan Message, 1)
+	io := NewIo(input, output)
+
+	color := Adapt(colorInput{Label: "Favourite color"})
+	if !isPrompt(color) {
+		t.Error("expected an adapted input to still be a prompt")
+	}
+	input <- Message{Type: "input", Data: "teal"}
+	v, err := io.AddToStack(color)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := <-output; m.Type != "colorInput" || v != "teal" {
+		t.Errorf("expected the legacy element to run, got %+v and %v", m, v)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = color.Execute(ctx, make(chan Message), make(chan Message))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the adapter to give up when the context is done, got %v", err)
+	}
+}
+
+func TestExecute_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	// nobody reads the output or answers, like a client that went away
+	for _, e := range []Executable{NewTextInput("Name"), HeadingDisplay{Text: "Hello"}, &ConfirmInput{Message: "Sure?"}} {
+		_, err := e.Execute(ctx, make(chan Message), make(chan Message))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected %T to give up at the deadline, got %v", e, err)
+		}
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	output := make(chan Message, 1)
+	_, err := NewTextInput("Name").Execute(ctx, make(chan Message), output)
+	if !errors.Is(err, context.DeadlineExceeded) || len(output) != 1 {
+		t.Errorf("expected the prompt to be sent and the wait for an answer to give up, got %v", err)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	output := make(chan Message, 2)
+	io := NewIo(make(chan Message), output)
+
+	_, err := io.Input.Text("Name", WithTimeout(10*time.Millisecond))
+	if !errors.Is(err, ErrInputTimeout) {
+		t.Fatalf("expected ErrInputTimeout, got %v", err)
+	}
+	if m := <-output; m.Type != "textInput" {
+		t.Errorf("expected the prompt, got %+v", m)
+	}
+	if m := <-output; m.Type != "inputTimeout" {
+		t.Errorf("expected the client to be told the prompt is closed, got %+v", m)
+	}
+
+	n, err := io.Input.Number("Seats", WithTimeout(10*time.Millisecond), WithDefault(3))
+	if err != nil || n != 3 {
+		t.Errorf("expected the default when it times out, got %d and %v", n, err)
+	}
+}
+
+func TestWithTimeout_Replay(t *testing.T) {
+	output := make(chan Message, 4)
+	io := NewIo(make(chan Message), output)
+	io.store = NewMemoryStore()
+	io.checkpoint = &Checkpoint{Session: "abc", Answers: []any{nil, "alice"}, TimedOut: []int{0}}
+
+	// the replay doesn't wait out the timeout again
+	seats, err := io.Input.Number("Seats", WithTimeout(time.Hour), WithDefault(1))
+	if err != nil || seats != 1 {
+		t.Errorf("expected the prompt to time out again, got %d and %v", seats, err)
+	}
+	name, err := io.Input.Text("Name", WithTimeout(time.Hour))
+	if err != nil || name != "alice" {
+		t.Errorf("expected the answer after the timeout to line up, got %q and %v", name, err)
+	}
+	for _, expected := range []string{"numberInput", "inputTimeout", "textInput", "input"} {
+		if m := <-output; m.Type != expected {
+			t.Errorf("expected %s, got %+v", expected, m)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package bff
+
+// LoadingState is the progress of a long running handler as the client sees it, each update replaces the last one
+type LoadingState struct {
+	Title string `json:"title,omitempty"`
+	// ItemsInQueue is how many items there are to work through, the client shows a progress bar when it is set and a
+	// spinner otherwise
+	ItemsInQueue   int `json:"itemsInQueue,omitempty"`
+	ItemsCompleted int `json:"itemsCompleted"`
+}
+
+// Loading represents the loading indicator, call methods to tell the user how a long running handler is getting on.
+// The indicator goes away when the next display or input is shown.
+type Loading struct {
+	io *Io
+}
+
+// Start shows a loading indicator with the title, itemsInQueue is how many items will be completed or 0 when that
+// isn't known
+func (l *Loading) Start(title string, itemsInQueue int) {
+	l.io.loading = LoadingState{Title: title, ItemsInQueue: itemsInQueue}
+	l.send()
+}
+
+// Update changes the title of the loading indicator
+func (l *Loading) Update(title string) {
+	l.io.loading.Title = title
+	l.send()
+}
+
+// CompleteOne marks an item in the queue as done
+func (l *Loading) CompleteOne() {
+	l.io.loading.ItemsCompleted++
+	l.send()
+}
+
+// send writes the state straight to the client rather than adding it to the stack, the progress isn't an answer to
+// anything and a checkpoint doesn't need to remember it
+func (l *Loading) send() {
+	state := l.io.loading
+	_ = send(l.io.ctx, l.io.output, Message{Type: "loading", Data: state})
+}
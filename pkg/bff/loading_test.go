@@ -0,0 +1,31 @@
+package bff
+
+import (
+	"testing"
+)
+
+func TestLoading(t *testing.T) {
+	output := make(chan Message, 4)
+	io := NewIo(nil, output)
+
+	io.Loading.Start("Sending emails", 2)
+	io.Loading.CompleteOne()
+	io.Loading.Update("Almost there")
+	io.Loading.CompleteOne()
+
+	expected := []LoadingState{
+		{Title: "Sending emails", ItemsInQueue: 2},
+		{Title: "Sending emails", ItemsInQueue: 2, ItemsCompleted: 1},
+		{Title: "Almost there", ItemsInQueue: 2, ItemsCompleted: 1},
+		{Title: "Almost there", ItemsInQueue: 2, ItemsCompleted: 2},
+	}
+	for _, e := range expected {
+		m := <-output
+		if m.Type != "loading" || m.Data != e {
+			t.Errorf("expected %+v, got %+v", e, m)
+		}
+	}
+	if len(io.stack) != 0 {
+		t.Errorf("expected loading to stay off the stack, got %d elements", len(io.stack))
+	}
+}
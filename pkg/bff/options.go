@@ -0,0 +1,136 @@
+package bff
+
+import "time"
+
+// InputOption configures what every input has in common, I.E WithHelpText or WithRequired, so it can be given to any
+// input. Inputs with settings of their own take their own kind of option as well, I.E Input.File takes FileOptions,
+// which WithAccept and every InputOption are, so giving WithAccept to a text input won't compile.
+// WithDefault and WithValidator are InputOptions too, the type they were given is only checked against the input when
+// it is made. An input given one of the wrong type returns an error when it is asked for, I.E a Number given
+// WithDefault("three").
+type InputOption func(*InputBase)
+
+// TextOption configures the text inputs, Text, TextArea and RichText
+type TextOption interface {
+	applyText(*InputBase)
+}
+
+// NumberOption configures a number input
+type NumberOption interface {
+	applyNumber(*NumberInput)
+}
+
+// SliderOption configures a slider
+type SliderOption interface {
+	applySlider(*SliderInput)
+}
+
+// DateOption configures a date input
+type DateOption interface {
+	applyDate(*DateInput)
+}
+
+// TimeOption configures a time input
+type TimeOption interface {
+	applyTime(*TimeInput)
+}
+
+// FileOption configures a file input
+type FileOption interface {
+	applyFile(*FileInput)
+}
+
+// SelectTableOption configures a select table input
+type SelectTableOption interface {
+	applySelectTable(*SelectTableInput)
+}
+
+func (o InputOption) applyText(b *InputBase)               { o(b) }
+func (o InputOption) applyNumber(n *NumberInput)           { o(&n.InputBase) }
+func (o InputOption) applySlider(s *SliderInput)           { o(&s.InputBase) }
+func (o InputOption) applyDate(d *DateInput)               { o(&d.InputBase) }
+func (o InputOption) applyTime(t *TimeInput)               { o(&t.InputBase) }
+func (o InputOption) applyFile(f *FileInput)               { o(&f.InputBase) }
+func (o InputOption) applySelectTable(s *SelectTableInput) { o(&s.InputBase) }
+
+// the options only one kind of input takes
+type textOption func(*InputBase)
+type numberOption func(*NumberInput)
+type sliderOption func(*SliderInput)
+type dateOption func(*DateInput)
+type timeOption func(*TimeInput)
+type fileOption func(*FileInput)
+type selectTableOption func(*SelectTableInput)
+
+func (o textOption) applyText(b *InputBase)                      { o(b) }
+func (o numberOption) applyNumber(n *NumberInput)                { o(n) }
+func (o sliderOption) applySlider(s *SliderInput)                { o(s) }
+func (o dateOption) applyDate(d *DateInput)                      { o(d) }
+func (o timeOption) applyTime(t *TimeInput)                      { o(t) }
+func (o fileOption) applyFile(f *FileInput)                      { o(f) }
+func (o selectTableOption) applySelectTable(s *SelectTableInput) { o(s) }
+
+// WithStep makes the slider move in steps of this size from its min
+func WithStep(step float64) SliderOption {
+	return sliderOption(func(s *SliderInput) {
+		s.Step = step
+	})
+}
+
+// WithMinDate is the earliest day a date input accepts
+func WithMinDate(t time.Time) DateOption {
+	return dateOption(func(d *DateInput) {
+		d.Min = t.Format(dateLayout)
+	})
+}
+
+// WithMaxDate is the latest day a date input accepts
+func WithMaxDate(t time.Time) DateOption {
+	return dateOption(func(d *DateInput) {
+		d.Max = t.Format(dateLayout)
+	})
+}
+
+// WithMinTime is the earliest time of day a time input accepts, only the hours and minutes of t are used
+func WithMinTime(t time.Time) TimeOption {
+	return timeOption(func(i *TimeInput) {
+		i.Min = t.Format(timeLayout)
+	})
+}
+
+// WithMaxTime is the latest time of day a time input accepts, only the hours and minutes of t are used
+func WithMaxTime(t time.Time) TimeOption {
+	return timeOption(func(i *TimeInput) {
+		i.Max = t.Format(timeLayout)
+	})
+}
+
+// WithAccept limits the files that can be uploaded, I.E "image/*,.csv". The types are checked against what the start of
+// the file looks like as well as what the client says it is, see http.DetectContentType. Give the extension for types it
+// doesn't know, I.E ".docx".
+func WithAccept(accept string) FileOption {
+	return fileOption(func(f *FileInput) {
+		f.Accept = accept
+	})
+}
+
+// WithMultiple lets the user upload more than one file
+func WithMultiple() FileOption {
+	return fileOption(func(f *FileInput) {
+		f.Multiple = true
+	})
+}
+
+// WithMaxFiles sets the most files that can be uploaded to an input given WithMultiple
+func WithMaxFiles(n int) FileOption {
+	return fileOption(func(f *FileInput) {
+		f.MaxFiles = n
+	})
+}
+
+// WithMaxFileSize sets the largest file in bytes that can be uploaded
+func WithMaxFileSize(size int64) FileOption {
+	return fileOption(func(f *FileInput) {
+		f.MaxSize = size
+	})
+}
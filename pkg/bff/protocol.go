@@ -0,0 +1,69 @@
+package bff
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ProtocolVersion is the version of the messages the BFF speaks. A client says which version it speaks when it
+// connects and every message after that carries it. Version 0 is a client from before messages were versioned, its
+// messages don't need an ID and its answers are taken to be for whatever prompt is waiting.
+const ProtocolVersion = 1
+
+var ErrVersionMismatch = errors.New("message is not in the protocol version of the connection")
+var ErrNoReplyTo = errors.New("an answer must say which prompt it is in reply to")
+var ErrStaleAnswer = errors.New("answer is not for the prompt that is waiting")
+var ErrUnknownMessage = errors.New("unknown message type")
+
+// Message represents a message with the backend
+type Message struct {
+	Type string `json:"type,omitempty"`
+	Data any    `json:"data,omitempty"`
+	// Run is the ID of the run the message is about, see Loop
+	Run string `json:"run,omitempty"`
+	// ID identifies the message, InReplyTo is the ID of the message it answers I.E the prompt an input is for
+	ID        string `json:"id,omitempty"`
+	InReplyTo string `json:"inReplyTo,omitempty"`
+	// Version is the ProtocolVersion the message was sent in, it is left out for version 0
+	Version int `json:"version,omitempty"`
+
+	// element is what an Io is about to run, see Io.announce. It is only for the loop, never the client.
+	element Executable
+}
+
+// Check makes sure a message from a client fits the protocol version the client connected with
+func (m Message) Check(version int) error {
+	if version == 0 {
+		return nil
+	}
+	if m.Version != version {
+		return fmt.Errorf("%w, got version %d on a version %d connection", ErrVersionMismatch, m.Version, version)
+	}
+	if m.Type == "input" && m.InReplyTo == "" {
+		return ErrNoReplyTo
+	}
+	return nil
+}
+
+// Reject is the message telling the client m was turned down and why, nothing else happens because of m
+func Reject(m Message, err error) Message {
+	return Message{Type: "rejected", Run: m.Run, ID: NewMessageID(), InReplyTo: m.ID, Data: err.Error()}
+}
+
+// NegotiateVersion picks the protocol version for a client that speaks the given version, a client newer than the
+// BFF is spoken to in the BFF's version
+func NegotiateVersion(client int) (int, error) {
+	if client < 0 {
+		return 0, fmt.Errorf("there is no protocol version %d", client)
+	}
+	return min(client, ProtocolVersion), nil
+}
+
+// NewMessageID makes up an ID for a message
+func NewMessageID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,84 @@
+package bff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Querier is an element that keeps answering the client after it has been added to the stack, I.E a table that sends
+// one page of its rows at a time. The client sends a "query" message naming the element and the loop replies with a
+// "queryResult" message, the handler is never involved so queries work while it is busy, waiting or even finished.
+type Querier interface {
+	// Query receives the query as it was decoded from the client's JSON, the result is sent back as JSON
+	Query(ctx context.Context, query any) (any, error)
+}
+
+// QueryRequest is the data of a "query" message from the client
+type QueryRequest struct {
+	ID    string `json:"id"`
+	Query any    `json:"query,omitempty"`
+}
+
+// QueryResult is the data of a "queryResult" message to the client
+type QueryResult struct {
+	ID     string `json:"id"`
+	Result any    `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// register makes the querier reachable by the client, the returned ID is what the client should send with its queries
+func (io *Io) register(q Querier) string {
+	io.mu.Lock()
+	defer io.mu.Unlock()
+	if io.queriers == nil {
+		io.queriers = make(map[string]Querier)
+	}
+	// IDs follow the order the handler adds elements, so they come out the same when a checkpoint is replayed
+	id := "q" + strconv.Itoa(len(io.queriers)+1)
+	io.queriers[id] = q
+	return id
+}
+
+func (io *Io) querier(id string) (Querier, bool) {
+	io.mu.Lock()
+	defer io.mu.Unlock()
+	q, ok := io.queriers[id]
+	return q, ok
+}
+
+// query answers a query message with the querier it names
+func (b *BFF) query(ctx context.Context, io *Io, m Message) Message {
+	var req QueryRequest
+	err := decodeData(m.Data, &req)
+	if err != nil {
+		return Message{Type: "queryResult", Data: QueryResult{Error: err.Error()}}
+	}
+	var q Querier
+	ok := false
+	if io != nil {
+		q, ok = io.querier(req.ID)
+	}
+	if !ok {
+		return Message{Type: "queryResult", Data: QueryResult{ID: req.ID, Error: "unknown query " + req.ID}}
+	}
+	result, err := q.Query(ctx, req.Query)
+	if err != nil {
+		return Message{Type: "queryResult", Data: QueryResult{ID: req.ID, Error: err.Error()}}
+	}
+	return Message{Type: "queryResult", Data: QueryResult{ID: req.ID, Result: result}}
+}
+
+// decodeData converts loosely typed message data, as it comes out of the JSON decoder, into v
+func decodeData(data any, v any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("decoding %T: %w", v, err)
+	}
+	return nil
+}
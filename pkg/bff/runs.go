@@ -0,0 +1,397 @@
+package bff
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrRunNotFound = errors.New("run not found")
+
+// Run is the record of one execution of an action, what was shown, what was asked and what the user answered
+type Run struct {
+	ID     string `json:"id"`
+	Action string `json:"action"`
+	// User is who ran the action, when the server knows
+	User     string    `json:"user,omitempty"`
+	Session  string    `json:"session,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Steps    []RunStep `json:"steps"`
+	Error    string    `json:"error,omitempty"`
+	Result   any       `json:"result,omitempty"`
+}
+
+// RunStep is an element that was added to the stack during a run
+type RunStep struct {
+	// Kind is the type of the element I.E TextInput or HeadingDisplay
+	Kind string `json:"kind"`
+	// Prompt is set for inputs, the answer is what the user entered
+	Prompt  bool            `json:"prompt,omitempty"`
+	Element json.RawMessage `json:"element,omitempty"`
+	Answer  any             `json:"answer,omitempty"`
+	At      time.Time       `json:"at"`
+}
+
+// RunFilter narrows down the runs a RunStore lists
+type RunFilter struct {
+	// Action only lists runs of the action with this slug
+	Action string
+	// Limit is the most runs to list, 0 lists them all
+	Limit int
+}
+
+// RunStore persists the record of every run. A run is saved when it starts and again as it goes, the last save of a run
+// with the ID replaces the ones before it. A run that never finished, I.E the process died, has no Finished time.
+type RunStore interface {
+	Save(ctx context.Context, run *Run) error
+	// Load returns ErrRunNotFound when there is no run with the ID
+	Load(ctx context.Context, id string) (*Run, error)
+	// List returns the runs matching the filter, the most recent first
+	List(ctx context.Context, filter RunFilter) ([]*Run, error)
+}
+
+// WithRunStore records every run of every action in the store
+func WithRunStore(store RunStore) Option {
+	return func(b *BFF) {
+		b.runs = store
+	}
+}
+
+// RunPolicy decides whether the user can read the record of a run, it has everything the user answered in it. user is
+// nil when nobody is signed in.
+type RunPolicy func(ctx context.Context, user *User, run *Run) bool
+
+// OwnRunsPolicy is the default RunPolicy, users only see the runs they started. Nobody signed in only sees the runs
+// nobody signed in started.
+func OwnRunsPolicy(ctx context.Context, user *User, run *Run) bool {
+	if user == nil {
+		return run.User == ""
+	}
+	return run.User == user.ID
+}
+
+// RolesRunPolicy lets users with one of the roles read every run, I.E "admin" or "audit", everyone else only reads their
+// own
+func RolesRunPolicy(roles ...string) RunPolicy {
+	return func(ctx context.Context, user *User, run *Run) bool {
+		if user != nil && slices.ContainsFunc(roles, func(role string) bool { return slices.Contains(user.Roles, role) }) {
+			return true
+		}
+		return OwnRunsPolicy(ctx, user, run)
+	}
+}
+
+// WithRunPolicy replaces the OwnRunsPolicy deciding who can read which runs
+func WithRunPolicy(policy RunPolicy) Option {
+	return func(b *BFF) {
+		b.runPolicy = policy
+	}
+}
+
+// Runs lists the recorded runs the user in the context can read, there are none when the BFF has no RunStore
+func (b *BFF) Runs(ctx context.Context, filter RunFilter) ([]*Run, error) {
+	if b.runs == nil {
+		return nil, nil
+	}
+	// the limit is of the runs the user can read, not of every run
+	limit := filter.Limit
+	filter.Limit = 0
+	runs, err := b.runs.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	readable := runs[:0]
+	for _, run := range runs {
+		if limit > 0 && len(readable) == limit {
+			break
+		}
+		if b.readable(ctx, run) {
+			readable = append(readable, run)
+		}
+	}
+	return readable, nil
+}
+
+// Run loads a recorded run, a run the user in the context can't read is ErrRunNotFound so nobody learns it exists
+func (b *BFF) Run(ctx context.Context, id string) (*Run, error) {
+	if b.runs == nil {
+		return nil, ErrRunNotFound
+	}
+	run, err := b.runs.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !b.readable(ctx, run) {
+		return nil, ErrRunNotFound
+	}
+	return run, nil
+}
+
+// readable checks the user in the context is allowed the action of the run, and the run policy lets them read it
+func (b *BFF) readable(ctx context.Context, run *Run) bool {
+	action, ok := b.Action(run.Action)
+	if !ok || !b.Allowed(ctx, action) {
+		return false
+	}
+	user, _ := UserFromContext(ctx)
+	if b.runPolicy == nil {
+		return OwnRunsPolicy(ctx, user, run)
+	}
+	return b.runPolicy(ctx, user, run)
+}
+
+// openRun makes the record of a run and saves it before the handler starts. The record has the ID the loop knows the
+// run by, a run resumed in its session carries on with the record it had. Any other run with the ID is left alone and
+// the run gets an ID of its own, I.E when the client picked an ID another session already used.
+func (b *BFF) openRun(ctx context.Context, action *Action, io *Io) {
+	run := &Run{ID: io.runID, Action: action.Slug, Session: io.session, Started: time.Now()}
+	if user, ok := UserFromContext(ctx); ok {
+		run.User = user.ID
+	}
+	if run.ID != "" {
+		previous, err := b.runs.Load(ctx, run.ID)
+		switch {
+		case errors.Is(err, ErrRunNotFound):
+		case err == nil && run.Session != "" && previous.Session == run.Session && previous.User == run.User &&
+			previous.Action == run.Action && previous.Finished.IsZero():
+			// the replay records the steps again
+			run.Started = previous.Started
+		default:
+			run.ID = ""
+		}
+	}
+	if run.ID == "" {
+		run.ID = newSessionID()
+	}
+	io.run, io.runs = run, b.runs
+	io.saveRun()
+}
+
+// saveRun saves the record of the run as it is, a run that can't be saved is logged rather than failing the action
+func (io *Io) saveRun() {
+	err := io.runs.Save(context.Background(), io.run)
+	if err != nil {
+		slog.Error("failed to save run", "run", io.run.ID, "action", io.run.Action, "err", err)
+	}
+}
+
+// record adds the element and its answer to the run, it does nothing when the run isn't being recorded
+func (io *Io) record(element Executable, answer any) {
+	if io.run == nil {
+		return
+	}
+	t := reflect.TypeOf(unwrap(element))
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	step := RunStep{Kind: t.Name(), Prompt: isPrompt(element), Answer: answer, At: time.Now()}
+	// encoded now, the element could be changed by the handler later on
+	b, err := json.Marshal(element)
+	if err == nil {
+		step.Element = b
+	}
+	io.run.Steps = append(io.run.Steps, step)
+	io.saveRun()
+}
+
+// MemoryRunStore keeps runs in memory, they are lost on restart
+type MemoryRunStore struct {
+	mu   sync.Mutex
+	runs []*Run
+}
+
+func NewMemoryRunStore() *MemoryRunStore {
+	return &MemoryRunStore{}
+}
+
+func (m *MemoryRunStore) Save(ctx context.Context, run *Run) error {
+	// copied through JSON so the run reads back the same way it would from disk
+	saved, err := copyRun(run)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.runs, func(r *Run) bool { return r.ID == run.ID })
+	if i >= 0 {
+		m.runs[i] = saved
+		return nil
+	}
+	m.runs = append(m.runs, saved)
+	return nil
+}
+
+func (m *MemoryRunStore) Load(ctx context.Context, id string) (*Run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.runs {
+		if r.ID == id {
+			return copyRun(r)
+		}
+	}
+	return nil, ErrRunNotFound
+}
+
+func (m *MemoryRunStore) List(ctx context.Context, filter RunFilter) ([]*Run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runs := make([]*Run, 0, len(m.runs))
+	for _, r := range m.runs {
+		c, err := copyRun(r)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, c)
+	}
+	return filterRuns(runs, filter), nil
+}
+
+func copyRun(run *Run) (*Run, error) {
+	b, err := json.Marshal(run)
+	if err != nil {
+		return nil, err
+	}
+	var c Run
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// FileRunStore appends every run to a JSON lines file, one run per line. Each save of a run is appended, the last line
+// with its ID is the one that counts.
+type FileRunStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileRunStore keeps the runs in the file at path, the file and its directory are created if they don't exist
+func NewFileRunStore(path string) (*FileRunStore, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("creating run log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening run log: %w", err)
+	}
+	_ = f.Close()
+	return &FileRunStore{path: path}, nil
+}
+
+func (f *FileRunStore) Save(ctx context.Context, run *Run) error {
+	b, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	line := append(b, '\n')
+	// a line half written when the process died is ended first, so this one isn't lost along with it
+	last := make([]byte, 1)
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+	_, err = file.Write(line)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (f *FileRunStore) Load(ctx context.Context, id string) (*Run, error) {
+	var found *Run
+	err := f.scan(func(r *Run) {
+		if r.ID == id {
+			found = r
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrRunNotFound
+	}
+	return found, nil
+}
+
+func (f *FileRunStore) List(ctx context.Context, filter RunFilter) ([]*Run, error) {
+	var runs []*Run
+	latest := make(map[string]int)
+	err := f.scan(func(r *Run) {
+		if i, ok := latest[r.ID]; ok {
+			runs[i] = r
+			return
+		}
+		latest[r.ID] = len(runs)
+		runs = append(runs, r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return filterRuns(runs, filter), nil
+}
+
+// scan reads every run in the file, a line that can't be read, I.E half written when the process died, is skipped
+func (f *FileRunStore) scan(fn func(*Run)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var r Run
+			if json.Unmarshal(line, &r) == nil {
+				fn(&r)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// filterRuns sorts the runs newest first and applies the filter
+func filterRuns(runs []*Run, filter RunFilter) []*Run {
+	filtered := runs[:0]
+	for _, r := range runs {
+		if filter.Action == "" || r.Action == filter.Action {
+			filtered = append(filtered, r)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].Started.After(filtered[j].Started)
+	})
+	if filter.Limit > 0 && len(filtered) > filter.Limit {
+		filtered = filtered[:filter.Limit]
+	}
+	return filtered
+}
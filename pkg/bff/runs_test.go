@@ -0,0 +1,211 @@
+package bff
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExecuteAction_RecordsRun(t *testing.T) {
+	runs := NewMemoryRunStore()
+	b := New(WithRunStore(runs))
+	err := b.RegisterAction("refund", func(ctx context.Context, io *Io) error {
+		io.Display.Heading("Refunds", 1)
+		customer, err := io.Input.Text("Customer")
+		if err != nil {
+			return err
+		}
+		return errors.New("no charges for " + customer)
+	}, WithSlug("refund_charges"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := make(chan Message, 1)
+	output := make(chan Message, 2)
+	input <- Message{Type: "input", Data: "cus_123"}
+	err = b.ExecuteAction(context.Background(), "refund_charges", input, output)
+	if err == nil {
+		t.Fatal("expected the handler error")
+	}
+
+	list, err := b.Runs(context.Background(), RunFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected one run, got %d", len(list))
+	}
+	run := list[0]
+	if run.Action != "refund_charges" || run.Error != "no charges for cus_123" || run.Finished.Before(run.Started) {
+		t.Errorf("unexpected run %+v", run)
+	}
+	if len(run.Steps) != 2 {
+		t.Fatalf("expected two steps, got %+v", run.Steps)
+	}
+	if s := run.Steps[0]; s.Kind != "HeadingDisplay" || s.Prompt {
+		t.Errorf("expected the heading, got %+v", s)
+	}
+	if s := run.Steps[1]; s.Kind != "TextInput" || !s.Prompt || s.Answer != "cus_123" || string(s.Element) != `{"label":"Customer"}` {
+		t.Errorf("expected the customer prompt, got %+v", s)
+	}
+
+	loaded, err := b.Run(context.Background(), run.ID)
+	if err != nil || loaded.ID != run.ID {
+		t.Errorf("expected to load run %s, got %+v %v", run.ID, loaded, err)
+	}
+	_, err = b.Run(context.Background(), "nope")
+	if !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("expected ErrRunNotFound, got %v", err)
+	}
+}
+
+func TestFileRunStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs", "runs.jsonl")
+	store, err := NewFileRunStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, action := range []string{"hello", "refund", "hello"} {
+		err := store.Save(ctx, &Run{ID: action + string(rune('a'+i)), Action: action, Started: start.Add(time.Duration(i) * time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a line half written when the process died doesn't lose the rest of the log
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"id":"broken`)
+	_ = f.Close()
+
+	list, err := store.List(ctx, RunFilter{Action: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "helloc" || list[1].ID != "helloa" {
+		t.Errorf("expected the hello runs newest first, got %+v", list)
+	}
+	list, err = store.List(ctx, RunFilter{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != "helloc" {
+		t.Errorf("expected the newest run, got %+v", list)
+	}
+	run, err := store.Load(ctx, "refundb")
+	if err != nil || run.Action != "refund" {
+		t.Errorf("expected the refund run, got %+v %v", run, err)
+	}
+	_, err = store.Load(ctx, "broken")
+	if !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("expected ErrRunNotFound, got %v", err)
+	}
+
+	// saving a run again replaces it
+	err = store.Save(ctx, &Run{ID: "refundb", Action: "refund", Started: start.Add(time.Hour), Error: "declined"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err = store.List(ctx, RunFilter{Action: "refund"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Error != "declined" {
+		t.Errorf("expected the last save of the run, got %+v", list)
+	}
+}
+
+func TestResumeSession_RunRecord(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	runs := NewMemoryRunStore()
+	app := New(WithStore(store), WithRunStore(runs))
+	err := app.RegisterAction("greet", func(ctx context.Context, io *Io) error {
+		name, err := io.Input.Text("What is your name?")
+		if err != nil {
+			return err
+		}
+		colour, err := io.Input.Text("What is your favourite colour?")
+		if err != nil {
+			return err
+		}
+		io.Display.Heading(name+" likes "+colour, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// as if the process died while waiting for the favourite colour, the run was recorded up to then
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = runs.Save(ctx, &Run{ID: "greeting", Action: "greet", Session: "abc", Started: started})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Save(ctx, &Checkpoint{Session: "abc", Run: "greeting", Action: "greet", Answers: []any{"gopher"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a run of another session that happens to have the ID the client picks below
+	err = runs.Save(ctx, &Run{ID: "taken", Action: "greet", Session: "xyz", Started: started})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := app.ResumeSession(ctx, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachment, err := session.Attach()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer attachment.Detach()
+	for _, expected := range []string{"textInput", "input", "textInput"} {
+		if m := receive(t, attachment); m.Type != expected {
+			t.Fatalf("expected %s while replaying, got %s", expected, m.Type)
+		}
+	}
+	run, err := runs.Load(ctx, "greeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !run.Started.Equal(started) || !run.Finished.IsZero() || len(run.Steps) != 1 || run.Steps[0].Answer != "gopher" {
+		t.Errorf("expected the resumed run to carry on with its record, got %+v", run)
+	}
+	err = session.Send(ctx, Message{Type: "input", Data: "blue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for receive(t, attachment).Type != "done" {
+	}
+	run, err = runs.Load(ctx, "greeting")
+	if err != nil || run.Finished.IsZero() || len(run.Steps) != 3 {
+		t.Errorf("expected the run to be finished with every step, got %+v %v", run, err)
+	}
+
+	err = session.Send(ctx, Message{Type: "start", Run: "taken", Data: "greet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive(t, attachment)
+	list, err := runs.List(ctx, RunFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("expected the new run to be recorded under an ID of its own, got %+v", list)
+	}
+	for _, r := range list {
+		if r.ID == "taken" && r.Session != "xyz" {
+			t.Errorf("expected the run of the other session to be left alone, got %+v", r)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package bff
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// SearchResult is how a search result is shown to the user
+type SearchResult struct {
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+	ImageUrl    string `json:"imageUrl,omitempty"`
+}
+
+// SearchResults is the answer to a search query, the query is sent back so the client can ignore stale results
+type SearchResults struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+}
+
+// SearchSelection is the result the user picked, the position in the results of the query they searched for
+type SearchSelection struct {
+	Query string `json:"query"`
+	Index int    `json:"index"`
+}
+
+// SearchInput is a search box, each query the user types is sent to the server which searches and sends the results
+// back. The initial results are the results of searching for an empty query.
+type SearchInput struct {
+	InputBase
+	ID             string         `json:"id"`
+	InitialResults []SearchResult `json:"initialResults"`
+
+	search func(ctx context.Context, query string) ([]SearchResult, error)
+
+	mu sync.Mutex
+	// query and found are the latest query the user searched for and how many results it had
+	query string
+	found int
+}
+
+func (s *SearchInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "searchInput", Data: s}, s.validate)
+}
+
+// Query runs the search for the query the user typed
+func (s *SearchInput) Query(ctx context.Context, query any) (any, error) {
+	q, ok := query.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected search query to be a string, got %T", query)
+	}
+	results, err := s.search(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	s.remember(q, len(results))
+	return SearchResults{Query: q, Results: results}, nil
+}
+
+func (s *SearchInput) remember(query string, found int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.query, s.found = query, found
+}
+
+// results is how many results the query has, a query other than the latest one is searched for again I.E when a
+// checkpoint is replayed
+func (s *SearchInput) results(ctx context.Context, query string) (int, error) {
+	s.mu.Lock()
+	if s.query == query {
+		defer s.mu.Unlock()
+		return s.found, nil
+	}
+	s.mu.Unlock()
+	results, err := s.search(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	s.remember(query, len(results))
+	return len(results), nil
+}
+
+// Search shows a search box, search is called as the user types and the result they pick is returned.
+// Use the Search function to get back the values that were searched for rather than where they were in the results.
+func (i *Input) Search(label string, search func(ctx context.Context, query string) ([]SearchResult, error), options ...InputOption) (SearchSelection, error) {
+	input := &SearchInput{InputBase: InputBase{Label: label}, search: search}
+	for _, option := range options {
+		option(&input.InputBase)
+	}
+	initial, err := search(i.io.ctx, "")
+	if err != nil {
+		return SearchSelection{}, err
+	}
+	input.InitialResults = initial
+	input.remember("", len(initial))
+	input.ID = i.io.register(input)
+
+	setup(&input.InputBase, func(v any) (SearchSelection, error) {
+		var selection SearchSelection
+		err := decodeData(v, &selection)
+		if err != nil {
+			return SearchSelection{}, fmt.Errorf("expected a search selection: %w", err)
+		}
+		found, err := input.results(i.io.ctx, selection.Query)
+		if err != nil {
+			return SearchSelection{}, err
+		}
+		if selection.Index < 0 || selection.Index >= found {
+			return SearchSelection{}, fmt.Errorf("search result %d for %q does not exist", selection.Index, selection.Query)
+		}
+		return selection, nil
+	})
+	return prompt[SearchSelection](i.io, input)
+}
+
+// Search shows a search box, onSearch is called as the user types and renderResult decides how each result looks.
+// The value the user picks is returned. When an action is resumed from a checkpoint the query that found the answer
+// is searched for again, so onSearch should give the same results for the same query.
+func Search[T any](io *Io, label string, onSearch func(ctx context.Context, query string) ([]T, error), renderResult func(T) SearchResult, options ...InputOption) (T, error) {
+	var zero T
+	var mu sync.Mutex
+	// only the latest query's results are kept, the selection is checked against them before the prompt closes
+	var latest struct {
+		query   string
+		results []T
+	}
+	var picked T
+
+	search := func(ctx context.Context, query string) ([]SearchResult, error) {
+		results, err := onSearch(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		latest.query, latest.results = query, results
+		mu.Unlock()
+
+		rendered := make([]SearchResult, len(results))
+		for i, r := range results {
+			rendered[i] = renderResult(r)
+		}
+		return rendered, nil
+	}
+	pick := WithValidator(func(selection SearchSelection) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if latest.query != selection.Query || selection.Index < 0 || selection.Index >= len(latest.results) {
+			return fmt.Errorf("search result %d for %q is no longer there, search again", selection.Index, selection.Query)
+		}
+		picked = latest.results[selection.Index]
+		return nil
+	})
+
+	_, err := io.Input.Search(label, search, append(options[:len(options):len(options)], pick)...)
+	if err != nil {
+		return zero, err
+	}
+	return picked, nil
+}
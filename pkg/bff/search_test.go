@@ -0,0 +1,85 @@
+package bff
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type user struct {
+	Name  string
+	Email string
+}
+
+func TestSearch(t *testing.T) {
+	users := []user{
+		{Name: "Alice", Email: "alice@example.com"},
+		{Name: "Bob", Email: "bob@example.com"},
+		{Name: "Bobby", Email: "bobby@example.com"},
+	}
+	searches := 0
+	onSearch := func(ctx context.Context, query string) ([]user, error) {
+		searches++
+		var found []user
+		for _, u := range users {
+			if strings.Contains(strings.ToLower(u.Name), query) {
+				found = append(found, u)
+			}
+		}
+		return found, nil
+	}
+	render := func(u user) SearchResult {
+		return SearchResult{Label: u.Name, Description: u.Email}
+	}
+
+	input := make(chan Message)
+	output := make(chan Message, 1)
+	io := NewIo(input, output)
+	type picked struct {
+		user user
+		err  error
+	}
+	done := make(chan picked)
+	go func() {
+		u, err := Search(io, "Find a user", onSearch, render)
+		done <- picked{u, err}
+	}()
+
+	m := <-output
+	search, ok := m.Data.(*SearchInput)
+	if !ok || m.Type != "searchInput" {
+		t.Fatalf("expected a searchInput, got %+v", m)
+	}
+	if len(search.InitialResults) != 3 {
+		t.Errorf("expected every user in the initial results, got %+v", search.InitialResults)
+	}
+
+	q, ok := io.querier(search.ID)
+	if !ok {
+		t.Fatal("expected the search to be queryable")
+	}
+	result, err := q.Query(context.Background(), "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := result.(SearchResults)
+	if found.Query != "bob" || len(found.Results) != 2 || found.Results[1].Label != "Bobby" {
+		t.Errorf("unexpected search results %+v", found)
+	}
+
+	input <- Message{Type: "input", Data: map[string]any{"query": "bob", "index": 2.0}}
+	if m := <-output; m.Type != "validationError" || m.Data != `search result 2 for "bob" does not exist` {
+		t.Fatalf("expected an out of range result to be a validationError, got %+v", m)
+	}
+	input <- Message{Type: "input", Data: map[string]any{"query": "bob", "index": 1.0}}
+	p := <-done
+	if p.err != nil {
+		t.Fatal(p.err)
+	}
+	if p.user.Email != "bobby@example.com" {
+		t.Errorf("expected Bobby to be picked, got %+v", p.user)
+	}
+	if searches != 2 {
+		t.Errorf("expected the picked result to come from the earlier search, searched %d times", searches)
+	}
+}
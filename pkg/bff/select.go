@@ -0,0 +1,208 @@
+package bff
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// SelectOption is one of the choices in a select input, the value is kept on the server and handed back when picked
+type SelectOption[T any] struct {
+	Label       string
+	Value       T
+	Description string
+	// Default marks the option as chosen to begin with
+	Default bool
+}
+
+// SelectChoice is a select option as the client sees it
+type SelectChoice struct {
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+	Default     bool   `json:"default,omitempty"`
+}
+
+// SelectInput asks the user to pick one, or with Multiple set any number, of a set of choices. The answer is the
+// position of the choices that were picked.
+type SelectInput struct {
+	InputBase
+	Options  []SelectChoice `json:"options"`
+	Multiple bool           `json:"multiple,omitempty"`
+}
+
+func (s *SelectInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	t := "selectSingleInput"
+	if s.Multiple {
+		t = "selectMultipleInput"
+	}
+	return ask(ctx, input, output, Message{Type: t, Data: s}, s.validate)
+}
+
+func (s *SelectInput) constrain() {
+	if s.Multiple {
+		setup(&s.InputBase, s.pickMultiple)
+		return
+	}
+	setup(&s.InputBase, s.pickSingle)
+}
+
+func (s *SelectInput) pickSingle(v any) (int, error) {
+	var picked int
+	err := decodeData(v, &picked)
+	if err != nil {
+		return 0, fmt.Errorf("expected a choice, got %T", v)
+	}
+	if picked < 0 || picked >= len(s.Options) {
+		return 0, fmt.Errorf("choice %d does not exist", picked)
+	}
+	return picked, nil
+}
+
+func (s *SelectInput) pickMultiple(v any) ([]int, error) {
+	var picked []int
+	err := decodeData(v, &picked)
+	if err != nil {
+		return nil, fmt.Errorf("expected a list of choices, got %T", v)
+	}
+	seen := make(map[int]bool, len(picked))
+	for _, p := range picked {
+		if p < 0 || p >= len(s.Options) {
+			return nil, fmt.Errorf("choice %d does not exist", p)
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("choice %d picked twice", p)
+		}
+		seen[p] = true
+	}
+	if s.Required && len(picked) == 0 {
+		return nil, ErrRequired
+	}
+	return picked, nil
+}
+
+// NewSelectSingleInput makes a select input where one choice is picked, the answer and what validators are given is
+// the position of the choice
+func NewSelectSingleInput(label string, choices []SelectChoice, options ...InputOption) *SelectInput {
+	input := &SelectInput{InputBase: InputBase{Label: label}, Options: choices}
+	for _, option := range options {
+		option(&input.InputBase)
+	}
+	input.constrain()
+	if d, ok := defaultOf[int](&input.InputBase); ok {
+		input.Options = chooseDefaults(&input.InputBase, choices, d)
+	}
+	return input
+}
+
+// SelectSingle asks the user to pick one of the choices and returns its position.
+// Use the SelectSingle function to get back the value of the option instead.
+func (i *Input) SelectSingle(label string, choices []SelectChoice, options ...InputOption) (int, error) {
+	return prompt[int](i.io, NewSelectSingleInput(label, choices, options...))
+}
+
+// NewSelectMultipleInput makes a select input where any number of choices are picked, the answer and what validators
+// are given is the positions of the choices
+func NewSelectMultipleInput(label string, choices []SelectChoice, options ...InputOption) *SelectInput {
+	input := &SelectInput{InputBase: InputBase{Label: label}, Options: choices, Multiple: true}
+	for _, option := range options {
+		option(&input.InputBase)
+	}
+	input.constrain()
+	if d, ok := defaultOf[[]int](&input.InputBase); ok {
+		input.Options = chooseDefaults(&input.InputBase, choices, d...)
+	}
+	return input
+}
+
+// SelectMultiple asks the user to pick any number of the choices and returns their positions.
+// Use the SelectMultiple function to get back the values of the options instead.
+func (i *Input) SelectMultiple(label string, choices []SelectChoice, options ...InputOption) ([]int, error) {
+	return prompt[[]int](i.io, NewSelectMultipleInput(label, choices, options...))
+}
+
+// chooseDefaults copies the choices with only the ones at the positions as the default
+func chooseDefaults(base *InputBase, choices []SelectChoice, positions ...int) []SelectChoice {
+	chosen := make([]SelectChoice, len(choices))
+	copy(chosen, choices)
+	for i := range chosen {
+		chosen[i].Default = false
+	}
+	for _, p := range positions {
+		if p < 0 || p >= len(chosen) {
+			base.err = fmt.Errorf("default choice %d for %q does not exist", p, base.Label)
+			return choices
+		}
+		chosen[p].Default = true
+	}
+	return chosen
+}
+
+// valueDefaults swaps a WithDefault of option values, a T or a []T, for the positions of those options. Values that
+// aren't one of the options are left out.
+func valueDefaults[T any](options []SelectOption[T], inputOptions []InputOption, multiple bool) []InputOption {
+	var scratch InputBase
+	for _, option := range inputOptions {
+		option(&scratch)
+	}
+	var values []T
+	switch d := scratch.initial.(type) {
+	case T:
+		values = []T{d}
+	case []T:
+		values = d
+	default:
+		return inputOptions
+	}
+	positions := make([]int, 0, len(values))
+	for _, v := range values {
+		for i, o := range options {
+			if reflect.DeepEqual(o.Value, v) {
+				positions = append(positions, i)
+				break
+			}
+		}
+	}
+	inputOptions = inputOptions[:len(inputOptions):len(inputOptions)]
+	switch {
+	case multiple:
+		return append(inputOptions, WithDefault(positions))
+	case len(positions) > 0:
+		return append(inputOptions, WithDefault(positions[0]))
+	}
+	return append(inputOptions, func(i *InputBase) {
+		i.initial = nil
+	})
+}
+
+// SelectSingle asks the user to pick one of the options and returns its value
+func SelectSingle[T any](io *Io, label string, options []SelectOption[T], inputOptions ...InputOption) (T, error) {
+	inputOptions = valueDefaults(options, inputOptions, false)
+	picked, err := io.Input.SelectSingle(label, selectChoices(options), inputOptions...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return options[picked].Value, nil
+}
+
+// SelectMultiple asks the user to pick any number of the options and returns their values
+func SelectMultiple[T any](io *Io, label string, options []SelectOption[T], inputOptions ...InputOption) ([]T, error) {
+	inputOptions = valueDefaults(options, inputOptions, true)
+	picked, err := io.Input.SelectMultiple(label, selectChoices(options), inputOptions...)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]T, 0, len(picked))
+	for _, p := range picked {
+		values = append(values, options[p].Value)
+	}
+	return values, nil
+}
+
+func selectChoices[T any](options []SelectOption[T]) []SelectChoice {
+	choices := make([]SelectChoice, len(options))
+	for i, o := range options {
+		choices[i] = SelectChoice{Label: o.Label, Description: o.Description, Default: o.Default}
+	}
+	return choices
+}
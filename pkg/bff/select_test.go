@@ -0,0 +1,100 @@
+package bff
+
+import (
+	"reflect"
+	"testing"
+)
+
+type plan struct {
+	ID    string
+	Price int
+}
+
+var plans = []SelectOption[plan]{
+	{Label: "Free", Value: plan{ID: "free"}},
+	{Label: "Pro", Value: plan{ID: "pro", Price: 10}, Default: true},
+	{Label: "Enterprise", Value: plan{ID: "enterprise", Price: 100}, Description: "call us"},
+}
+
+func TestSelectSingle(t *testing.T) {
+	input := make(chan Message, 1)
+	output := make(chan Message, 1)
+	io := NewIo(input, output)
+
+	input <- Message{Type: "input", Data: 2.0}
+	p, err := SelectSingle(io, "Plan", plans)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := <-output
+	if m.Type != "selectSingleInput" || !m.Data.(*SelectInput).Options[1].Default {
+		t.Errorf("unexpected message %+v", m)
+	}
+	if p.ID != "enterprise" {
+		t.Errorf("expected the enterprise plan, got %+v", p)
+	}
+
+	for _, answer := range []any{3.0, -1.0, 1.5, "pro"} {
+		rejected(t, answer, func(io *Io) error {
+			_, err := SelectSingle(io, "Plan", plans)
+			return err
+		})
+	}
+}
+
+func TestSelectMultiple(t *testing.T) {
+	input := make(chan Message, 1)
+	output := make(chan Message, 1)
+	io := NewIo(input, output)
+
+	input <- Message{Type: "input", Data: []any{2.0, 0.0}}
+	picked, err := SelectMultiple(io, "Plans", plans)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := <-output; m.Type != "selectMultipleInput" {
+		t.Errorf("expected selectMultipleInput, got %s", m.Type)
+	}
+	if !reflect.DeepEqual(picked, []plan{{ID: "enterprise", Price: 100}, {ID: "free"}}) {
+		t.Errorf("unexpected plans %+v", picked)
+	}
+
+	msg := rejected(t, []any{}, func(io *Io) error {
+		_, err := SelectMultiple(io, "Plans", plans, WithRequired(true))
+		return err
+	})
+	if msg != ErrRequired.Error() {
+		t.Errorf("expected a required select to need at least one choice, got %q", msg)
+	}
+}
+
+func TestSelect_Defaults(t *testing.T) {
+	input := make(chan Message, 1)
+	output := make(chan Message, 1)
+	io := NewIo(input, output)
+
+	input <- Message{Type: "input", Data: 0.0}
+	_, err := SelectSingle(io, "Plan", plans, WithDefault(plans[0].Value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	choices := (<-output).Data.(*SelectInput).Options
+	if !choices[0].Default || choices[1].Default {
+		t.Errorf("expected only the default plan to be chosen, got %+v", choices)
+	}
+
+	input <- Message{Type: "input", Data: []any{}}
+	_, err = io.Input.SelectMultiple("Plans", selectChoices(plans), WithDefault([]int{0, 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	choices = (<-output).Data.(*SelectInput).Options
+	if !choices[0].Default || choices[1].Default || !choices[2].Default {
+		t.Errorf("expected the first and last plans to be chosen, got %+v", choices)
+	}
+
+	_, err = io.Input.SelectSingle("Plan", selectChoices(plans), WithDefault(7))
+	if err == nil {
+		t.Error("expected a default choice that doesn't exist to be refused")
+	}
+}
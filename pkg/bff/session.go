@@ -0,0 +1,296 @@
+package bff
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+var ErrSessionClosed = errors.New("session closed")
+var ErrSessionNotFound = errors.New("session not found")
+
+// DefaultSessionTTL is how long a session is kept alive without a connected client before it is thrown away
+const DefaultSessionTTL = 10 * time.Minute
+
+// Session is a running BFF loop that outlives any single connection. A client that drops its connection can attach
+// to the session again with the session ID, the handler stays parked on whatever it was waiting for and the history of
+// the Io stack is replayed to the new connection.
+type Session struct {
+	ID string
+
+	bff *BFF
+	// user is who started the session, only they can attach to it
+	user   *User
+	input  chan Message
+	output chan Message
+	cancel context.CancelFunc
+	done   chan struct{}
+	sendMu sync.RWMutex
+
+	mu      sync.Mutex
+	history []Message
+	// over are the IDs of the runs that are done, their history is dropped when the next run starts
+	over     map[string]bool
+	attached *Attachment
+	expiry   *time.Timer
+	closed   bool
+}
+
+// Attachment is a client connected to a session
+type Attachment struct {
+	// History is everything the session has sent so far, send it to the client before anything from Messages
+	History []Message
+	// Messages are sent by the session from now on
+	Messages <-chan Message
+	// Done is closed when another client takes over the session or the session ends
+	Done <-chan struct{}
+
+	session  *Session
+	messages chan Message
+	done     chan struct{}
+}
+
+// NewSession starts a new session running the BFF loop, the session is registered so it can be found by ID later.
+// The session belongs to the user in the context, if any, and the handlers it runs get the values of the context but
+// aren't cancelled with it.
+func (b *BFF) NewSession(ctx context.Context) *Session {
+	return b.startSession(ctx, newSessionID(), nil)
+}
+
+// ResumeSession brings back a session that was lost in a restart from its checkpoints, each action it was running is
+// replayed up to the first prompt that was not answered. It returns ErrSessionNotFound when there is nothing to resume
+// or the session belongs to someone other than the user in the context.
+func (b *BFF) ResumeSession(ctx context.Context, id string) (*Session, error) {
+	user, _ := UserFromContext(ctx)
+	if s, ok := b.Session(id); ok {
+		if !sameUser(s.user, user) {
+			return nil, ErrSessionNotFound
+		}
+		return s, nil
+	}
+	if b.store == nil {
+		return nil, ErrSessionNotFound
+	}
+	checkpoints, err := b.store.Load(ctx, id)
+	if errors.Is(err, ErrCheckpointNotFound) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, checkpoint := range checkpoints {
+		if !sameUser(checkpoint.User, user) {
+			return nil, ErrSessionNotFound
+		}
+		slog.Info("resuming run from checkpoint", "session", id, "run", checkpoint.Run, "action", checkpoint.Action, "answers", len(checkpoint.Answers))
+	}
+	s := b.startSession(ctx, id, checkpoints)
+	if !sameUser(s.user, user) {
+		// somebody else resumed it first
+		return nil, ErrSessionNotFound
+	}
+	return s, nil
+}
+
+// Session finds a running session by its ID
+func (b *BFF) Session(id string) (*Session, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	s, ok := b.sessions[id]
+	return s, ok
+}
+
+func (b *BFF) startSession(ctx context.Context, id string, resume []*Checkpoint) *Session {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s, ok := b.sessions[id]; ok {
+		// somebody else got here first
+		return s
+	}
+
+	user, _ := UserFromContext(ctx)
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s := &Session{
+		ID:     id,
+		bff:    b,
+		user:   user,
+		input:  make(chan Message),
+		output: make(chan Message, 1),
+		cancel: cancel,
+		done:   make(chan struct{}),
+		over:   make(map[string]bool),
+	}
+	// nobody is attached yet, so the expiry clock starts right away
+	s.expiry = time.AfterFunc(b.sessionTTL, s.expire)
+	b.sessions[s.ID] = s
+
+	go func() {
+		b.loop(ctx, s.ID, resume, s.accept, s.input, s.output)
+		close(s.output)
+	}()
+	go s.drain()
+	return s
+}
+
+// accept records an answer the loop has given to a handler, so a replay shows the prompt as answered. Answers the
+// loop turns away are left out.
+func (s *Session) accept(m Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = append(s.history, m)
+}
+
+// drain records everything the loop sends and forwards it to the attached client, if there is one
+func (s *Session) drain() {
+	for m := range s.output {
+		s.mu.Lock()
+		switch m.Type {
+		case "queryResult", "rejected":
+			// the client asks again for what it needs after a reconnect, there is no end to them otherwise. A rejected
+			// answer never made it into the history, so neither does turning it down.
+		case "loading":
+			// each update replaces the last one of the run
+			s.history = slices.DeleteFunc(s.history, func(h Message) bool {
+				return h.Type == "loading" && h.Run == m.Run
+			})
+			s.history = append(s.history, m)
+		case "done", "error", "cancelled":
+			s.over[m.Run] = true
+			s.history = append(s.history, m)
+		default:
+			s.history = append(s.history, m)
+		}
+		a := s.attached
+		s.mu.Unlock()
+		if a == nil {
+			continue
+		}
+		select {
+		case a.messages <- m:
+		case <-a.done:
+		}
+	}
+	slog.Debug("session loop finished", "session", s.ID)
+	s.mu.Lock()
+	s.closeLocked()
+	s.mu.Unlock()
+}
+
+// Attach connects a client to the session, any client that was attached before is detached
+func (s *Session) Attach() (*Attachment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrSessionClosed
+	}
+	if s.attached != nil {
+		close(s.attached.done)
+	}
+	s.expiry.Stop()
+
+	a := &Attachment{
+		History:  make([]Message, len(s.history)),
+		session:  s,
+		messages: make(chan Message),
+		done:     make(chan struct{}),
+	}
+	copy(a.History, s.history)
+	a.Messages, a.Done = a.messages, a.done
+	s.attached = a
+	return a, nil
+}
+
+// Detach must be called when the client goes away, the session is kept around for a while so the client can come back
+func (a *Attachment) Detach() {
+	s := a.session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attached != a {
+		return
+	}
+	close(a.done)
+	s.attached = nil
+	if !s.closed {
+		s.expiry.Reset(s.bff.sessionTTL)
+	}
+}
+
+// Send passes a message from the client to the loop, blocking until the loop accepts it
+func (s *Session) Send(ctx context.Context, m Message) error {
+	s.sendMu.RLock()
+	defer s.sendMu.RUnlock()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrSessionClosed
+	}
+	switch m.Type {
+	case "start":
+		// the runs that are done have nothing left to replay, the ones still going do
+		history := s.history[:0]
+		for _, h := range s.history {
+			if h.Run != "" && !s.over[h.Run] {
+				history = append(history, h)
+			}
+		}
+		s.history = history
+		clear(s.over)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		return ErrSessionClosed
+	case s.input <- m:
+		return nil
+	}
+}
+
+// expire throws the session away after it has had no client attached for too long
+func (s *Session) expire() {
+	s.mu.Lock()
+	if s.attached != nil || s.closed {
+		s.mu.Unlock()
+		return
+	}
+	slog.Debug("session expired", "session", s.ID)
+	s.closeLocked()
+	s.mu.Unlock()
+
+	// closing the input unblocks a handler parked on a prompt, wait for stragglers to give up sending first
+	s.sendMu.Lock()
+	close(s.input)
+	s.sendMu.Unlock()
+}
+
+func (s *Session) closeLocked() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.cancel()
+	s.expiry.Stop()
+	if s.attached != nil {
+		close(s.attached.done)
+		s.attached = nil
+	}
+
+	s.bff.mu.Lock()
+	delete(s.bff.sessions, s.ID)
+	s.bff.mu.Unlock()
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,122 @@
+package bff
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSession_History(t *testing.T) {
+	ctx := context.Background()
+	app := New()
+	err := app.RegisterAction("report", func(ctx context.Context, io *Io) error {
+		io.Loading.Start("Counting", 2)
+		io.Loading.CompleteOne()
+		io.Loading.CompleteOne()
+		err := io.Display.Table("Counts", []struct{ Count int }{{1}, {2}})
+		if err != nil {
+			return err
+		}
+		_, err = io.Input.Text("Anything else?")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := app.NewSession(ctx)
+	attachment, err := session.Attach()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = session.Send(ctx, Message{Type: "start", Data: "report"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var table Message
+	for table.Type != "table" {
+		table = receive(t, attachment)
+	}
+	receive(t, attachment) // the prompt
+	for range 3 {
+		err = session.Send(ctx, Message{Type: "query", Run: table.Run, Data: map[string]any{"id": table.Data.(*TableDisplay).ID}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m := receive(t, attachment); m.Type != "queryResult" {
+			t.Fatalf("expected a query result, got %+v", m)
+		}
+	}
+	attachment.Detach()
+
+	// a client coming back gets the latest progress and what is on screen, not every page it looked at
+	attachment, err = session.Attach()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer attachment.Detach()
+	var types []string
+	for _, m := range attachment.History {
+		types = append(types, m.Type)
+	}
+	if len(types) != 3 || types[0] != "loading" || types[1] != "table" || types[2] != "textInput" {
+		t.Fatalf("expected the latest progress, the table and the prompt, got %v", types)
+	}
+	if state := attachment.History[0].Data.(LoadingState); state.ItemsCompleted != 2 {
+		t.Errorf("expected the latest progress, got %+v", state)
+	}
+}
+
+func TestSession_RejectedAnswers(t *testing.T) {
+	ctx := context.Background()
+	app := New()
+	err := app.RegisterAction("greet", func(ctx context.Context, io *Io) error {
+		name, err := io.Input.Text("Name?")
+		if err != nil {
+			return err
+		}
+		io.Display.Heading("Hello "+name, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := app.NewSession(ctx)
+	attachment, err := session.Attach()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = session.Send(ctx, Message{Type: "start", Data: "greet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompt := receive(t, attachment)
+	err = session.Send(ctx, Message{Type: "input", Data: "mallory", ID: "stale", InReplyTo: "gone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := receive(t, attachment); m.Type != "rejected" {
+		t.Fatalf("expected the stale answer to be rejected, got %+v", m)
+	}
+	err = session.Send(ctx, Message{Type: "input", Data: "alice", ID: "answer", InReplyTo: prompt.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for receive(t, attachment).Type != "done" {
+	}
+	attachment.Detach()
+
+	// only the answer the handler got is replayed
+	attachment, err = session.Attach()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer attachment.Detach()
+	var answers []any
+	for _, m := range attachment.History {
+		if m.Type == "input" || m.Type == "rejected" {
+			answers = append(answers, m.Data)
+		}
+	}
+	if len(answers) != 1 || answers[0] != "alice" {
+		t.Fatalf("expected only the accepted answer in the history, got %v", answers)
+	}
+}
@@ -0,0 +1,188 @@
+package bff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// Checkpoint is how far a session got through an action, it holds the result of every element on the Io stack so the
+// handler can be replayed up to the first unanswered prompt after the process restarts.
+// Replaying only works if the handler asks for the same things in the same order given the same answers.
+type Checkpoint struct {
+	Session string `json:"session"`
+	// Run is the ID of the run in the session, it keeps its ID when it is resumed
+	Run    string `json:"run,omitempty"`
+	Action string `json:"action"`
+	// User started the session, only they can resume it
+	User *User `json:"user,omitempty"`
+	// Answers has one entry per element on the stack, displays have a nil answer
+	Answers []any `json:"answers"`
+	// TimedOut are the positions of the prompts that were given up on by WithTimeout
+	TimedOut []int `json:"timedOut,omitempty"`
+}
+
+// Store persists checkpoints of running actions, there is one for each run in a session
+type Store interface {
+	// Save replaces the checkpoint of the run in the session
+	Save(ctx context.Context, checkpoint *Checkpoint) error
+	// Load returns the checkpoints of every run in the session, ErrCheckpointNotFound when there are none
+	Load(ctx context.Context, session string) ([]*Checkpoint, error)
+	Delete(ctx context.Context, session string, run string) error
+}
+
+// MemoryStore keeps checkpoints in memory, it survives reconnects but not restarts so it is mostly useful for tests
+type MemoryStore struct {
+	mu sync.Mutex
+	// checkpoints are by session then run
+	checkpoints map[string]map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{checkpoints: make(map[string]map[string][]byte)}
+}
+
+func (m *MemoryStore) Save(ctx context.Context, checkpoint *Checkpoint) error {
+	// store it encoded so the answers come back the same way they would from disk
+	b, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runs, ok := m.checkpoints[checkpoint.Session]
+	if !ok {
+		runs = make(map[string][]byte)
+		m.checkpoints[checkpoint.Session] = runs
+	}
+	runs[checkpoint.Run] = b
+	return nil
+}
+
+func (m *MemoryStore) Load(ctx context.Context, session string) ([]*Checkpoint, error) {
+	m.mu.Lock()
+	encoded := slices.Collect(maps.Values(m.checkpoints[session]))
+	m.mu.Unlock()
+	if len(encoded) == 0 {
+		return nil, ErrCheckpointNotFound
+	}
+	checkpoints := make([]*Checkpoint, len(encoded))
+	for i, b := range encoded {
+		err := json.Unmarshal(b, &checkpoints[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return checkpoints, nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, session string, run string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.checkpoints[session], run)
+	if len(m.checkpoints[session]) == 0 {
+		delete(m.checkpoints, session)
+	}
+	return nil
+}
+
+// FileStore keeps a JSON file per run in a directory, named for the session and the run
+type FileStore struct {
+	dir string
+}
+
+// validID keeps client supplied session and run IDs from escaping the store directory, or from having the dot that
+// separates them in the file name
+var validID = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// NewFileStore creates a store in dir, creating the directory if it does not exist
+func NewFileStore(dir string) (*FileStore, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("creating checkpoint directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (f *FileStore) path(session string, run string) (string, error) {
+	if !validID.MatchString(session) {
+		return "", fmt.Errorf("invalid session id %q", session)
+	}
+	if !validID.MatchString(run) {
+		return "", fmt.Errorf("invalid run id %q", run)
+	}
+	return filepath.Join(f.dir, session+"."+run+".json"), nil
+}
+
+func (f *FileStore) Save(ctx context.Context, checkpoint *Checkpoint) error {
+	p, err := f.path(checkpoint.Session, checkpoint.Run)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	// write then rename so a crash part way through never leaves a torn checkpoint
+	tmp := p + ".tmp"
+	err = os.WriteFile(tmp, b, 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (f *FileStore) Load(ctx context.Context, session string) ([]*Checkpoint, error) {
+	if !validID.MatchString(session) {
+		return nil, fmt.Errorf("invalid session id %q", session)
+	}
+	files, err := filepath.Glob(filepath.Join(f.dir, session+".*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrCheckpointNotFound
+	}
+	checkpoints := make([]*Checkpoint, 0, len(files))
+	for _, p := range files {
+		b, err := os.ReadFile(p)
+		if errors.Is(err, os.ErrNotExist) {
+			// the run finished while we were looking
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var checkpoint Checkpoint
+		err = json.Unmarshal(b, &checkpoint)
+		if err != nil {
+			return nil, fmt.Errorf("decoding checkpoint %s: %w", p, err)
+		}
+		checkpoints = append(checkpoints, &checkpoint)
+	}
+	if len(checkpoints) == 0 {
+		return nil, ErrCheckpointNotFound
+	}
+	return checkpoints, nil
+}
+
+func (f *FileStore) Delete(ctx context.Context, session string, run string) error {
+	p, err := f.path(session, run)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,245 @@
+package bff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Load(ctx, "missing")
+	if !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("expected ErrCheckpointNotFound, got %v", err)
+	}
+
+	err = store.Save(ctx, &Checkpoint{Session: "abc", Run: "left", Action: "greet", Answers: []any{nil, "gopher", true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Save(ctx, &Checkpoint{Session: "abc", Run: "right", Action: "refund"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoints, err := store.Load(ctx, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoints) != 2 {
+		t.Fatalf("expected a checkpoint for each run, got %+v", checkpoints)
+	}
+	for _, checkpoint := range checkpoints {
+		if checkpoint.Run == "left" && (checkpoint.Action != "greet" || len(checkpoint.Answers) != 3 || checkpoint.Answers[1] != "gopher") {
+			t.Errorf("unexpected checkpoint %+v", checkpoint)
+		}
+	}
+
+	err = store.Delete(ctx, "abc", "left")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoints, err = store.Load(ctx, "abc")
+	if err != nil || len(checkpoints) != 1 || checkpoints[0].Run != "right" {
+		t.Errorf("expected only the other run to be left, got %+v %v", checkpoints, err)
+	}
+	err = store.Delete(ctx, "abc", "right")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Load(ctx, "abc")
+	if !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("expected checkpoint to be deleted, got %v", err)
+	}
+
+	err = store.Save(ctx, &Checkpoint{Session: "../escape", Run: "run"})
+	if err == nil {
+		t.Error("expected session ids with path separators to be rejected")
+	}
+	err = store.Save(ctx, &Checkpoint{Session: "abc", Run: "../escape"})
+	if err == nil {
+		t.Error("expected run ids with path separators to be rejected")
+	}
+}
+
+func TestResumeSession(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	app := New(WithStore(store))
+	err := app.RegisterAction("greet", func(ctx context.Context, io *Io) error {
+		io.Display.Heading("Greetings", 1)
+		name, err := io.Input.Text("What is your name?")
+		if err != nil {
+			return err
+		}
+		colour, err := io.Input.Text("What is your favourite colour?")
+		if err != nil {
+			return err
+		}
+		io.Display.Heading(name+" likes "+colour, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// as if the process died while waiting for the favourite colour
+	err = store.Save(ctx, &Checkpoint{Session: "abc", Run: "greeting", Action: "greet", Answers: []any{nil, "gopher"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = app.ResumeSession(ctx, "nope")
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+	session, err := app.ResumeSession(ctx, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachment, err := session.Attach()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer attachment.Detach()
+
+	for _, expected := range []string{"display", "textInput", "input", "textInput"} {
+		if m := receive(t, attachment); m.Type != expected {
+			t.Fatalf("expected %s while replaying, got %s", expected, m.Type)
+		}
+	}
+	err = session.Send(ctx, Message{Type: "input", Data: "blue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := receive(t, attachment)
+	if h, ok := m.Data.(HeadingDisplay); !ok || h.Text != "gopher likes blue" {
+		t.Errorf("expected the handler to carry on with the replayed answer, got %+v", m)
+	}
+	if m := receive(t, attachment); m.Type != "done" {
+		t.Errorf("expected done, got %s", m.Type)
+	}
+	_, err = store.Load(ctx, "abc")
+	if !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("expected checkpoint to be deleted once the action finished, got %v", err)
+	}
+}
+
+func TestResumeSession_SeveralRuns(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	greet := func(ctx context.Context, io *Io) error {
+		name, err := io.Input.Text("What is your name?")
+		if err != nil {
+			return err
+		}
+		colour, err := io.Input.Text("What is your favourite colour?")
+		if err != nil {
+			return err
+		}
+		io.Display.Heading(name+" likes "+colour, 1)
+		return nil
+	}
+	app := New(WithStore(store))
+	err := app.RegisterAction("greet", greet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := app.NewSession(ctx)
+	attachment, err := session.Attach()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, run := range []string{"left", "right"} {
+		err = session.Send(ctx, Message{Type: "start", Run: run, Data: "greet"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		receive(t, attachment)
+		err = session.Send(ctx, Message{Type: "input", Run: run, Data: run + " gopher"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		receive(t, attachment)
+	}
+	attachment.Detach()
+
+	// as if the process restarted with both runs waiting on the favourite colour
+	restarted := New(WithStore(store))
+	err = restarted.RegisterAction("greet", greet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := restarted.ResumeSession(ctx, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachment, err = resumed.Attach()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer attachment.Detach()
+	for _, run := range []string{"left", "right"} {
+		err = resumed.Send(ctx, Message{Type: "input", Run: run, Data: "blue"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	liked := map[string]string{}
+	for len(liked) < 2 {
+		m := receive(t, attachment)
+		if h, ok := m.Data.(HeadingDisplay); ok {
+			liked[m.Run] = h.Text
+		}
+	}
+	if liked["left"] != "left gopher likes blue" || liked["right"] != "right gopher likes blue" {
+		t.Errorf("expected both runs to carry on where they left off, got %v", liked)
+	}
+}
+
+func TestResumeSession_OtherUser(t *testing.T) {
+	store := NewMemoryStore()
+	app := New(WithStore(store))
+	alice := ContextWithUser(context.Background(), &User{ID: "alice"})
+	bob := ContextWithUser(context.Background(), &User{ID: "bob"})
+
+	session := app.NewSession(alice)
+	if _, err := app.ResumeSession(bob, session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected bob to be kept out of alice's session, got %v", err)
+	}
+	if _, err := app.ResumeSession(context.Background(), session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected nobody to be kept out of alice's session, got %v", err)
+	}
+	if s, err := app.ResumeSession(alice, session.ID); err != nil || s != session {
+		t.Errorf("expected alice to get her session back, got %v", err)
+	}
+
+	err := store.Save(context.Background(), &Checkpoint{Session: "abc", Run: "greeting", Action: "greet", User: &User{ID: "alice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.ResumeSession(bob, "abc"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected bob to be kept out of alice's checkpoint, got %v", err)
+	}
+}
+
+// receive takes the next message from the attachment, starting with the history it was attached with
+func receive(t *testing.T, a *Attachment) Message {
+	t.Helper()
+	if len(a.History) > 0 {
+		m := a.History[0]
+		a.History = a.History[1:]
+		return m
+	}
+	select {
+	case m := <-a.Messages:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return Message{}
+	}
+}
@@ -0,0 +1,552 @@
+package bff
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTablePageSize is how many rows a table shows at once unless told otherwise
+const DefaultTablePageSize = 20
+
+// maxTablePageSize stops a client from asking for every row at once
+const maxTablePageSize = 500
+
+// TableColumn is one column of a table
+type TableColumn struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+// TableQuery is what the client sends to get another page of a table
+type TableQuery struct {
+	// Page is zero based
+	Page     int    `json:"page"`
+	PageSize int    `json:"pageSize,omitempty"`
+	SortBy   string `json:"sortBy,omitempty"`
+	Desc     bool   `json:"desc,omitempty"`
+}
+
+// TablePage is one page of a table, the cells are already formatted for display
+type TablePage struct {
+	Rows [][]string `json:"rows"`
+	// Indexes are the positions of the rows in the slice the table was made from
+	Indexes  []int  `json:"indexes"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"pageSize"`
+	Total    int    `json:"total"`
+	SortBy   string `json:"sortBy,omitempty"`
+	Desc     bool   `json:"desc,omitempty"`
+}
+
+// TableDisplay shows tabular data. The rows stay on the server and the client queries them a page at a time, sorted
+// however it likes, so a table can hold far more rows than would be sensible to send to a browser.
+type TableDisplay struct {
+	ID      string        `json:"id"`
+	Label   string        `json:"label,omitempty"`
+	Columns []TableColumn `json:"columns"`
+	// Page is the first page, so there is something to show before the client asks for more
+	Page TablePage `json:"page"`
+
+	pageSize   int
+	columns    []string
+	labels     map[string]string
+	formatters map[string]func(any) string
+
+	// values holds every row, one value per column
+	values [][]any
+
+	// the last sort order is kept, paging through a sorted table is the common case
+	mu        sync.Mutex
+	order     []int
+	orderBy   string
+	orderDesc bool
+}
+
+type TableOption func(*TableDisplay)
+
+// WithTableColumns picks the columns to show and the order to show them in, by struct field name or map key
+func WithTableColumns(keys ...string) TableOption {
+	return func(t *TableDisplay) {
+		t.columns = keys
+	}
+}
+
+// WithTableColumnLabel renames the heading of a column
+func WithTableColumnLabel(key string, label string) TableOption {
+	return func(t *TableDisplay) {
+		t.labels[key] = label
+	}
+}
+
+// WithTableFormatter controls how the values of a column are displayed
+func WithTableFormatter(key string, format func(v any) string) TableOption {
+	return func(t *TableDisplay) {
+		t.formatters[key] = format
+	}
+}
+
+// WithTablePageSize sets how many rows are shown at once
+func WithTablePageSize(size int) TableOption {
+	return func(t *TableDisplay) {
+		t.pageSize = size
+	}
+}
+
+// NewTable builds a table from a slice of structs or maps with string keys. Struct columns come from the exported
+// fields in order, a `bff:"label=Customer ID"` tag renames a column and `bff:"-"` leaves the field out.
+// Map columns are every key found in the rows, sorted.
+func NewTable(label string, rows any, opts ...TableOption) (*TableDisplay, error) {
+	t := &TableDisplay{
+		Label:      label,
+		pageSize:   DefaultTablePageSize,
+		labels:     make(map[string]string),
+		formatters: make(map[string]func(any) string),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.pageSize <= 0 || t.pageSize > maxTablePageSize {
+		return nil, fmt.Errorf("table page size must be between 1 and %d, got %d", maxTablePageSize, t.pageSize)
+	}
+
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("table rows must be a slice, got %T", rows)
+	}
+	elem := v.Type().Elem()
+	for elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+
+	var columns []TableColumn
+	var extract func(row reflect.Value) []any
+	switch {
+	case elem.Kind() == reflect.Struct:
+		columns, extract = structColumns(elem)
+	case elem.Kind() == reflect.Map && elem.Key().Kind() == reflect.String:
+		columns, extract = mapColumns(v)
+	default:
+		return nil, fmt.Errorf("table rows must be structs or maps with string keys, got %s", elem)
+	}
+
+	// pick and order the columns, then apply the labels
+	if t.columns != nil {
+		byKey := make(map[string]int, len(columns))
+		for i, c := range columns {
+			byKey[c.Key] = i
+		}
+		picked := make([]int, 0, len(t.columns))
+		for _, key := range t.columns {
+			i, ok := byKey[key]
+			if !ok {
+				return nil, fmt.Errorf("table has no column %q", key)
+			}
+			picked = append(picked, i)
+		}
+		all := extract
+		extract = func(row reflect.Value) []any {
+			values := all(row)
+			out := make([]any, len(picked))
+			for j, i := range picked {
+				out[j] = values[i]
+			}
+			return out
+		}
+		selected := make([]TableColumn, len(picked))
+		for j, i := range picked {
+			selected[j] = columns[i]
+		}
+		columns = selected
+	}
+	for i, c := range columns {
+		if l, ok := t.labels[c.Key]; ok {
+			columns[i].Label = l
+		}
+	}
+	t.Columns = columns
+
+	t.values = make([][]any, v.Len())
+	for i := range v.Len() {
+		row := v.Index(i)
+		for row.Kind() == reflect.Pointer || row.Kind() == reflect.Interface {
+			row = row.Elem()
+		}
+		if !row.IsValid() {
+			return nil, fmt.Errorf("table row %d is nil", i)
+		}
+		t.values[i] = extract(row)
+	}
+
+	page, err := t.page(TableQuery{})
+	if err != nil {
+		return nil, err
+	}
+	t.Page = page
+	return t, nil
+}
+
+func structColumns(t reflect.Type) ([]TableColumn, func(row reflect.Value) []any) {
+	var columns []TableColumn
+	var fields [][]int
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		tag := parseTag(f.Tag.Get("bff"))
+		if _, skip := tag["-"]; skip {
+			continue
+		}
+		label := f.Name
+		if l, ok := tag["label"]; ok {
+			label = l
+		}
+		columns = append(columns, TableColumn{Key: f.Name, Label: label})
+		fields = append(fields, f.Index)
+	}
+	return columns, func(row reflect.Value) []any {
+		values := make([]any, len(fields))
+		for i, index := range fields {
+			// a nil embedded pointer just leaves its fields empty
+			f, err := row.FieldByIndexErr(index)
+			if err == nil {
+				values[i] = f.Interface()
+			}
+		}
+		return values
+	}
+}
+
+func mapColumns(rows reflect.Value) ([]TableColumn, func(row reflect.Value) []any) {
+	keys := make(map[string]bool)
+	for i := range rows.Len() {
+		row := rows.Index(i)
+		for row.Kind() == reflect.Pointer || row.Kind() == reflect.Interface {
+			row = row.Elem()
+		}
+		if !row.IsValid() {
+			continue
+		}
+		for _, k := range row.MapKeys() {
+			keys[k.String()] = true
+		}
+	}
+	var columns []TableColumn
+	for k := range keys {
+		columns = append(columns, TableColumn{Key: k, Label: k})
+	}
+	slices.SortFunc(columns, func(a, b TableColumn) int { return strings.Compare(a.Key, b.Key) })
+	return columns, func(row reflect.Value) []any {
+		values := make([]any, len(columns))
+		for i, c := range columns {
+			v := row.MapIndex(reflect.ValueOf(c.Key).Convert(row.Type().Key()))
+			if v.IsValid() {
+				values[i] = v.Interface()
+			}
+		}
+		return values
+	}
+}
+
+// parseTag reads a `bff:"label=Name,required"` style tag, flags without a value are set to "true"
+func parseTag(tag string) map[string]string {
+	parsed := make(map[string]string)
+	if tag == "" {
+		return parsed
+	}
+	for _, part := range strings.Split(tag, ",") {
+		k, v, ok := strings.Cut(part, "=")
+		k = strings.TrimSpace(k)
+		if !ok {
+			v = "true"
+		}
+		if k != "" {
+			parsed[k] = v
+		}
+	}
+	return parsed
+}
+
+func (t *TableDisplay) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return nil, send(ctx, output, Message{Type: "table", Data: t})
+}
+
+func (t *TableDisplay) Prompt() bool { return false }
+
+// Query returns the page of rows the client asked for
+func (t *TableDisplay) Query(ctx context.Context, query any) (any, error) {
+	var q TableQuery
+	err := decodeData(query, &q)
+	if err != nil {
+		return nil, err
+	}
+	return t.page(q)
+}
+
+func (t *TableDisplay) page(q TableQuery) (TablePage, error) {
+	if q.PageSize <= 0 {
+		q.PageSize = t.pageSize
+	}
+	if q.PageSize > maxTablePageSize {
+		q.PageSize = maxTablePageSize
+	}
+	if q.Page < 0 {
+		q.Page = 0
+	}
+	column := -1
+	if q.SortBy != "" {
+		for i, c := range t.Columns {
+			if c.Key == q.SortBy {
+				column = i
+			}
+		}
+		if column < 0 {
+			return TablePage{}, fmt.Errorf("table has no column %q", q.SortBy)
+		}
+	}
+
+	order := t.sorted(column, q.SortBy, q.Desc)
+	// a page past the end is the empty page after the last, multiplying a huge page number would overflow
+	q.Page = min(q.Page, (len(order)+q.PageSize-1)/q.PageSize)
+	start := min(q.Page*q.PageSize, len(order))
+	end := min(start+q.PageSize, len(order))
+	rows := make([][]string, 0, end-start)
+	indexes := make([]int, 0, end-start)
+	for _, i := range order[start:end] {
+		indexes = append(indexes, i)
+		cells := make([]string, len(t.Columns))
+		for j, c := range t.Columns {
+			cells[j] = t.format(c.Key, t.values[i][j])
+		}
+		rows = append(rows, cells)
+	}
+	return TablePage{
+		Rows:     rows,
+		Indexes:  indexes,
+		Page:     q.Page,
+		PageSize: q.PageSize,
+		Total:    len(t.values),
+		SortBy:   q.SortBy,
+		Desc:     q.Desc,
+	}, nil
+}
+
+// sorted returns the row indexes in the requested order, column is -1 for the order the rows were given in
+func (t *TableDisplay) sorted(column int, key string, desc bool) []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.order != nil && t.orderBy == key && t.orderDesc == desc {
+		return t.order
+	}
+	order := make([]int, len(t.values))
+	for i := range order {
+		order[i] = i
+	}
+	if column >= 0 {
+		sort.SliceStable(order, func(a, b int) bool {
+			c := compareValues(t.values[order[a]][column], t.values[order[b]][column])
+			if desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	} else if desc {
+		slices.Reverse(order)
+	}
+	t.order, t.orderBy, t.orderDesc = order, key, desc
+	return order
+}
+
+func (t *TableDisplay) format(key string, v any) string {
+	if f, ok := t.formatters[key]; ok {
+		return f(v)
+	}
+	return formatValue(v)
+}
+
+// formatValue is how a value is displayed when nobody said otherwise
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	case error:
+		return v.Error()
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return ""
+		}
+		return formatValue(rv.Elem().Interface())
+	}
+	return fmt.Sprint(v)
+}
+
+// compareValues orders two cells of the same column, nils sort first and anything unusual is compared as text
+func compareValues(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			return at.Compare(bt)
+		}
+	}
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case isInt(av) && isInt(bv):
+		return cmp.Compare(av.Int(), bv.Int())
+	case isUint(av) && isUint(bv):
+		return cmp.Compare(av.Uint(), bv.Uint())
+	case isNumber(av) && isNumber(bv):
+		return cmp.Compare(toFloat(av), toFloat(bv))
+	case av.Kind() == reflect.String && bv.Kind() == reflect.String:
+		return strings.Compare(av.String(), bv.String())
+	case av.Kind() == reflect.Bool && bv.Kind() == reflect.Bool:
+		switch {
+		case av.Bool() == bv.Bool():
+			return 0
+		case bv.Bool():
+			return -1
+		default:
+			return 1
+		}
+	}
+	return strings.Compare(formatValue(a), formatValue(b))
+}
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func isNumber(v reflect.Value) bool {
+	return isInt(v) || isUint(v) || v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch {
+	case isInt(v):
+		return float64(v.Int())
+	case isUint(v):
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
+
+// SelectTableInput asks the user to pick rows from a table, the answer is the indexes of the rows they picked
+type SelectTableInput struct {
+	InputBase
+	Table         *TableDisplay `json:"table"`
+	MinSelections int           `json:"minSelections,omitempty"`
+	MaxSelections int           `json:"maxSelections,omitempty"`
+
+	tableOptions []TableOption
+}
+
+// WithSelectionLimits sets how many rows must be picked, a max of 0 means there is no limit
+func WithSelectionLimits(min, max int) SelectTableOption {
+	return selectTableOption(func(s *SelectTableInput) {
+		s.MinSelections = min
+		s.MaxSelections = max
+	})
+}
+
+// WithTableOptions configures the table the rows are picked from
+func WithTableOptions(options ...TableOption) SelectTableOption {
+	return selectTableOption(func(s *SelectTableInput) {
+		s.tableOptions = append(s.tableOptions, options...)
+	})
+}
+
+func (s *SelectTableInput) Execute(ctx context.Context, input <-chan Message, output chan<- Message) (any, error) {
+	return ask(ctx, input, output, Message{Type: "selectTableInput", Data: s}, s.validate)
+}
+
+// selected checks the answer is a list of distinct rows within the limits
+func (s *SelectTableInput) selected(v any) ([]int, error) {
+	var indexes []int
+	err := decodeData(v, &indexes)
+	if err != nil {
+		return nil, fmt.Errorf("expected a list of rows, got %T", v)
+	}
+	seen := make(map[int]bool, len(indexes))
+	for _, i := range indexes {
+		if i < 0 || i >= len(s.Table.values) {
+			return nil, fmt.Errorf("selected row %d does not exist", i)
+		}
+		if seen[i] {
+			return nil, fmt.Errorf("row %d selected twice", i)
+		}
+		seen[i] = true
+	}
+	if len(indexes) < s.MinSelections {
+		return nil, fmt.Errorf("expected at least %d rows selected, got %d", s.MinSelections, len(indexes))
+	}
+	if s.MaxSelections > 0 && len(indexes) > s.MaxSelections {
+		return nil, fmt.Errorf("expected at most %d rows selected, got %d", s.MaxSelections, len(indexes))
+	}
+	return indexes, nil
+}
+
+// SelectTable asks the user to pick rows from a table of structs or maps and returns the indexes of the rows picked.
+// Use the SelectTable function to get the rows themselves back.
+func (i *Input) SelectTable(label string, rows any, options ...SelectTableOption) ([]int, error) {
+	input := &SelectTableInput{InputBase: InputBase{Label: label}}
+	for _, option := range options {
+		option.applySelectTable(input)
+	}
+	table, err := NewTable(label, rows, input.tableOptions...)
+	if err != nil {
+		return nil, err
+	}
+	table.ID = i.io.register(table)
+	input.Table = table
+
+	setup(&input.InputBase, input.selected)
+	return prompt[[]int](i.io, input)
+}
+
+// SelectTable asks the user to pick rows from a table and returns the rows they picked
+func SelectTable[T any](io *Io, label string, rows []T, options ...SelectTableOption) ([]T, error) {
+	indexes, err := io.Input.SelectTable(label, rows, options...)
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]T, 0, len(indexes))
+	for _, i := range indexes {
+		selected = append(selected, rows[i])
+	}
+	return selected, nil
+}
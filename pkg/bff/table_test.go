@@ -0,0 +1,153 @@
+package bff
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type charge struct {
+	ID       int    `bff:"label=Charge ID"`
+	Customer string `bff:"label=Customer"`
+	Amount   float64
+	secret   string
+	Internal string `bff:"-"`
+}
+
+func testCharges() []charge {
+	return []charge{
+		{ID: 1, Customer: "carol", Amount: 20.5, secret: "hunter2", Internal: "x"},
+		{ID: 2, Customer: "alice", Amount: 3},
+		{ID: 3, Customer: "bob", Amount: 100},
+	}
+}
+
+func TestNewTable_Columns(t *testing.T) {
+	table, err := NewTable("Charges", testCharges())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []TableColumn{
+		{Key: "ID", Label: "Charge ID"},
+		{Key: "Customer", Label: "Customer"},
+		{Key: "Amount", Label: "Amount"},
+	}
+	if !reflect.DeepEqual(table.Columns, expected) {
+		t.Errorf("expected columns %+v, got %+v", expected, table.Columns)
+	}
+	if table.Page.Total != 3 || !reflect.DeepEqual(table.Page.Rows[0], []string{"1", "carol", "20.5"}) {
+		t.Errorf("unexpected first page %+v", table.Page)
+	}
+
+	t.Run("columns can be picked, relabelled and formatted", func(t *testing.T) {
+		table, err := NewTable("Charges", testCharges(),
+			WithTableColumns("Amount", "Customer"),
+			WithTableColumnLabel("Amount", "Total"),
+			WithTableFormatter("Customer", func(v any) string { return strings.ToUpper(v.(string)) }),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if table.Columns[0].Label != "Total" || len(table.Columns) != 2 {
+			t.Errorf("unexpected columns %+v", table.Columns)
+		}
+		if !reflect.DeepEqual(table.Page.Rows[1], []string{"3", "ALICE"}) {
+			t.Errorf("unexpected row %+v", table.Page.Rows[1])
+		}
+	})
+
+	t.Run("maps use their keys", func(t *testing.T) {
+		table, err := NewTable("Maps", []map[string]any{{"b": 1, "a": "x"}, {"c": true}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(table.Columns) != 3 || table.Columns[0].Key != "a" || table.Columns[2].Key != "c" {
+			t.Errorf("unexpected columns %+v", table.Columns)
+		}
+	})
+
+	t.Run("rejects things that aren't rows", func(t *testing.T) {
+		_, err := NewTable("Nope", []int{1, 2})
+		if err == nil {
+			t.Error("expected an error for a slice of ints")
+		}
+		_, err = NewTable("Nope", testCharges(), WithTableColumns("Missing"))
+		if err == nil {
+			t.Error("expected an error for a missing column")
+		}
+	})
+}
+
+func TestTableDisplay_Query(t *testing.T) {
+	table, err := NewTable("Charges", testCharges(), WithTablePageSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the query arrives however the JSON decoder left it
+	result, err := table.Query(context.Background(), map[string]any{"page": 1.0, "sortBy": "Amount", "desc": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := result.(TablePage)
+	if page.Total != 3 || len(page.Rows) != 1 || page.Rows[0][1] != "alice" {
+		t.Errorf("expected the cheapest charge on the second page, got %+v", page)
+	}
+
+	result, err = table.Query(context.Background(), map[string]any{"sortBy": "Customer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page = result.(TablePage)
+	if page.Rows[0][1] != "alice" || page.Rows[1][1] != "bob" {
+		t.Errorf("expected customers in order, got %+v", page.Rows)
+	}
+
+	result, err = table.Query(context.Background(), map[string]any{"page": 3.6e16, "pageSize": 500.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page = result.(TablePage); len(page.Rows) != 0 {
+		t.Errorf("expected no rows on a page far past the end, got %+v", page)
+	}
+
+	_, err = table.Query(context.Background(), map[string]any{"sortBy": "secret"})
+	if err == nil {
+		t.Error("expected an error sorting by a column that isn't shown")
+	}
+}
+
+func TestSelectTable(t *testing.T) {
+	input := make(chan Message, 1)
+	output := make(chan Message, 1)
+	io := NewIo(input, output)
+
+	input <- Message{Type: "input", Data: []any{2.0, 0.0}}
+	picked, err := SelectTable(io, "Charges to refund", testCharges(), WithSelectionLimits(1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := <-output; m.Type != "selectTableInput" {
+		t.Errorf("expected selectTableInput, got %s", m.Type)
+	}
+	if len(picked) != 2 || picked[0].ID != 3 || picked[1].ID != 1 {
+		t.Errorf("expected the charges picked in order, got %+v", picked)
+	}
+
+	for name, answer := range map[string]any{
+		"too many":      []any{0.0, 1.0, 2.0},
+		"too few":       []any{},
+		"missing row":   []any{7.0},
+		"repeated row":  []any{1.0, 1.0},
+		"not a list":    "all of them",
+		"not a number":  []any{"1"},
+		"negative rows": []any{-1.0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rejected(t, answer, func(io *Io) error {
+				_, err := SelectTable(io, "Charges to refund", testCharges(), WithSelectionLimits(1, 2))
+				return err
+			})
+		})
+	}
+}
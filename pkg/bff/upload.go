@@ -0,0 +1,281 @@
+package bff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var ErrUploadNotFound = errors.New("upload not found")
+var ErrUploadTooLarge = errors.New("upload too large")
+var ErrUploadNotAccepted = errors.New("file type not accepted")
+var ErrUploadOutOfOrder = errors.New("upload chunk out of order")
+
+// DefaultMaxFileSize is the largest file a file input accepts unless told otherwise
+const DefaultMaxFileSize = 32 << 20
+
+// DefaultMaxFiles is the most files a file input that takes more than one accepts unless told otherwise
+const DefaultMaxFiles = 10
+
+// UploadedFile is a file the user uploaded, it can be opened until the action is over
+type UploadedFile struct {
+	Name string
+	Type string
+	Size int64
+
+	path string
+}
+
+// Open reads the contents of the file
+func (f UploadedFile) Open() (io.ReadCloser, error) {
+	return os.Open(f.path)
+}
+
+// UploadChunk describes a piece of a file sent to an upload, files are sent in order one chunk at a time
+type UploadChunk struct {
+	// File is the position of the file among the files of the upload
+	File int
+	Name string
+	Type string
+	// Size is the size of the whole file
+	Size int64
+	// Offset is where in the file the chunk goes, sending the file from 0 again starts it over
+	Offset int64
+}
+
+// uploads are where the files for file inputs are sent, each file input gets its own upload with a random ID the
+// client uses to send files to it. The files are kept in temporary files until the action is over.
+type uploads struct {
+	mu    sync.Mutex
+	slots map[string]*upload
+}
+
+type upload struct {
+	mu    sync.Mutex
+	input *FileInput
+	// user is who was asked for the files, nobody else can send them
+	user     *User
+	files    map[int]*uploadingFile
+	resolved bool
+}
+
+type uploadingFile struct {
+	UploadedFile
+	file    *os.File
+	written int64
+}
+
+func newUploads() *uploads {
+	return &uploads{slots: make(map[string]*upload)}
+}
+
+// open makes a place for the files the user is asked for by the input to be sent to
+func (u *uploads) open(input *FileInput, user *User) string {
+	id := newSessionID()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.slots[id] = &upload{input: input, user: user, files: make(map[int]*uploadingFile)}
+	return id
+}
+
+func (u *uploads) get(id string) (*upload, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	s, ok := u.slots[id]
+	return s, ok
+}
+
+// close removes the uploads and throws their files away
+func (u *uploads) close(ids ...string) {
+	u.mu.Lock()
+	closing := make([]*upload, 0, len(ids))
+	for _, id := range ids {
+		if s, ok := u.slots[id]; ok {
+			closing = append(closing, s)
+			delete(u.slots, id)
+		}
+	}
+	u.mu.Unlock()
+
+	for _, s := range closing {
+		s.mu.Lock()
+		for _, f := range s.files {
+			if f.file != nil {
+				_ = f.file.Close()
+			}
+			_ = os.Remove(f.path)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Upload writes a chunk of a file to the upload with the given ID, the file input that made the upload decides which
+// files it accepts and how big they can be. Only the user in the context that was asked for the files can send them.
+func (b *BFF) Upload(ctx context.Context, id string, chunk UploadChunk, body io.Reader) error {
+	if b.uploads == nil {
+		return ErrUploadNotFound
+	}
+	s, ok := b.uploads.get(id)
+	user, _ := UserFromContext(ctx)
+	if !ok || !sameUser(s.user, user) {
+		return ErrUploadNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resolved {
+		return ErrUploadNotFound
+	}
+
+	f, ok := s.files[chunk.File]
+	if !ok && chunk.Offset != 0 {
+		return ErrUploadOutOfOrder
+	}
+	// a file sent from the start again is a retry, or another file picked after the answer was turned down
+	if chunk.Offset == 0 {
+		if chunk.File < 0 || (chunk.File > 0 && !s.input.Multiple) {
+			return fmt.Errorf("%w: only one file can be uploaded", ErrUploadNotAccepted)
+		}
+		if max := s.input.maxFiles(); chunk.File >= max {
+			return fmt.Errorf("%w: at most %d files can be uploaded", ErrUploadNotAccepted, max)
+		}
+		if chunk.Size < 0 || chunk.Size > s.input.MaxSize {
+			return ErrUploadTooLarge
+		}
+		if !accepts(s.input.Accept, chunk.Name, chunk.Type) {
+			return ErrUploadNotAccepted
+		}
+		// the type the client says the file is can't be trusted, what the start of it looks like has to fit as well
+		head := make([]byte, 512)
+		n, err := io.ReadFull(body, head)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+		head = head[:n]
+		if !accepts(s.input.Accept, chunk.Name, http.DetectContentType(head)) {
+			return ErrUploadNotAccepted
+		}
+		body = io.MultiReader(bytes.NewReader(head), body)
+		if ok {
+			// what was sent before is thrown away
+			if f.file != nil {
+				_ = f.file.Close()
+			}
+			_ = os.Remove(f.path)
+			delete(s.files, chunk.File)
+		}
+		tmp, err := os.CreateTemp("", "bff-upload-*")
+		if err != nil {
+			return err
+		}
+		f = &uploadingFile{
+			UploadedFile: UploadedFile{Name: filepath.Base(chunk.Name), Type: chunk.Type, Size: chunk.Size, path: tmp.Name()},
+			file:         tmp,
+		}
+		s.files[chunk.File] = f
+	}
+	if chunk.Offset != f.written || f.file == nil {
+		return ErrUploadOutOfOrder
+	}
+
+	// read one byte past what is left, so a client sending more than it said it would is caught
+	remaining := f.Size - f.written
+	n, err := io.CopyN(f.file, body, remaining+1)
+	f.written += n
+	if f.written > f.Size {
+		return ErrUploadTooLarge
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if f.written == f.Size {
+		err = f.file.Close()
+		f.file = nil
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve turns the answer of a file input, the positions of the files that were uploaded, into the files. No more
+// files can be uploaded once it is resolved.
+func (u *uploads) resolve(id string, answer any) ([]UploadedFile, error) {
+	return u.picked(id, answer, true)
+}
+
+// check is resolve without closing the upload, so the user can fix the answer
+func (u *uploads) check(id string, answer any) ([]UploadedFile, error) {
+	return u.picked(id, answer, false)
+}
+
+func (u *uploads) picked(id string, answer any, resolve bool) ([]UploadedFile, error) {
+	var picked []int
+	err := decodeData(answer, &picked)
+	if err != nil {
+		return nil, fmt.Errorf("expected a list of uploaded files, got %T", answer)
+	}
+	s, ok := u.get(id)
+	if !ok {
+		// the answer came from a checkpoint, the files themselves did not survive the restart
+		return nil, fmt.Errorf("uploaded files are gone: %w", ErrUploadNotFound)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resolve {
+		s.resolved = true
+	}
+
+	if len(picked) > 1 && !s.input.Multiple {
+		return nil, fmt.Errorf("expected one file, got %d", len(picked))
+	}
+	if len(picked) == 0 && s.input.Required {
+		return nil, ErrRequired
+	}
+	files := make([]UploadedFile, 0, len(picked))
+	for _, p := range picked {
+		f, ok := s.files[p]
+		if !ok || f.file != nil {
+			return nil, fmt.Errorf("file %d was not uploaded", p)
+		}
+		files = append(files, f.UploadedFile)
+	}
+	return files, nil
+}
+
+// accepts checks a file against an accept list like the HTML accept attribute, I.E "image/*,.csv,application/pdf"
+func accepts(accept string, name string, contentType string) bool {
+	if strings.TrimSpace(accept) == "" {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+	for _, a := range strings.Split(accept, ",") {
+		a = strings.ToLower(strings.TrimSpace(a))
+		switch {
+		case a == "":
+			continue
+		case strings.HasPrefix(a, "."):
+			if ext == a {
+				return true
+			}
+		case strings.HasSuffix(a, "/*"):
+			if mediaType != "" && strings.HasPrefix(mediaType, strings.TrimSuffix(a, "*")) {
+				return true
+			}
+		case mediaType == a:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package bff
+
+import (
+	"context"
+)
+
+// User is who is running an action, the server works it out from the request and handlers get it from their context
+// with UserFromContext
+type User struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	// Roles decide which actions the user can run, see WithRoles
+	Roles []string `json:"roles,omitempty"`
+}
+
+type userKey struct{}
+
+// ContextWithUser returns a context carrying the user
+func ContextWithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the user running the action, ok is false when nobody is signed in I.E the server has no
+// Authenticator
+func UserFromContext(ctx context.Context) (user *User, ok bool) {
+	user, ok = ctx.Value(userKey{}).(*User)
+	return user, ok && user != nil
+}
+
+// sameUser checks two users are the same person, nobody is the same as nobody
+func sameUser(a *User, b *User) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ID == b.ID
+}
@@ -0,0 +1,326 @@
+package bff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrRequired = errors.New("a value is required")
+
+// WithValidator checks the answer before the prompt is closed, when fn returns an error the user is shown it and gets
+// to try again. T is the type the input returns, I.E string for Text, int for Number or time.Time for Date. The input
+// returns an error when it is asked for with a validator of another type.
+func WithValidator[T any](fn func(v T) error) InputOption {
+	return func(i *InputBase) {
+		i.validators = append(i.validators, fn)
+	}
+}
+
+// WithMinLength is the fewest characters a text input accepts
+func WithMinLength(n int) TextOption {
+	return textOption(func(i *InputBase) {
+		i.minLength = n
+	})
+}
+
+// WithMaxLength is the most characters a text input accepts
+func WithMaxLength(n int) TextOption {
+	return textOption(func(i *InputBase) {
+		i.maxLength = n
+	})
+}
+
+// WithMin is the smallest number a number input accepts
+func WithMin(min float64) NumberOption {
+	return numberOption(func(n *NumberInput) {
+		n.min = &min
+	})
+}
+
+// WithMax is the largest number a number input accepts
+func WithMax(max float64) NumberOption {
+	return numberOption(func(n *NumberInput) {
+		n.max = &max
+	})
+}
+
+// ask sends the prompt and waits for an answer that passes validate. An answer that doesn't is sent back as a
+// validationError and the prompt stays open for the user to correct it. It gives up when the context is done.
+func ask(ctx context.Context, input <-chan Message, output chan<- Message, prompt Message, validate func(any) error) (any, error) {
+	err := send(ctx, output, prompt)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var m Message
+		var ok bool
+		select {
+		case m, ok = <-input:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !ok {
+			return nil, errors.New("input closed before a valid answer was given")
+		}
+		if m.Type != "input" {
+			return nil, fmt.Errorf("expected input, got %s", m.Type)
+		}
+		if validate == nil {
+			return m.Data, nil
+		}
+		err := validate(m.Data)
+		if err == nil {
+			return m.Data, nil
+		}
+		// a group says which of its inputs were wrong
+		var data any = err.Error()
+		var fields FieldErrors
+		if errors.As(err, &fields) {
+			data = fields
+		}
+		err = send(ctx, output, Message{Type: "validationError", Data: data})
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// field is an input that was made by one of the New...Input constructors, so it knows how to check its answer
+type field interface {
+	Executable
+	base() *InputBase
+}
+
+func (b *InputBase) base() *InputBase {
+	return b
+}
+
+// setup gives the input its parse, parse turns the raw answer in to a T and checks the constraints of the input, then
+// the validators added with WithValidator have their say
+func setup[T any](base *InputBase, parse func(any) (T, error)) {
+	var zero T
+	checks := make([]func(T) error, 0, len(base.validators))
+	for _, v := range base.validators {
+		check, ok := v.(func(T) error)
+		if !ok {
+			base.err = fmt.Errorf("validator for %q is a %T, the input needs a func(%T) error", base.Label, v, zero)
+			return
+		}
+		checks = append(checks, check)
+	}
+	base.parse = func(v any) (any, error) {
+		t, err := parse(v)
+		if err != nil {
+			return nil, err
+		}
+		for _, check := range checks {
+			err = check(t)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return t, nil
+	}
+}
+
+// constrained is an input that builds its parse from its exported fields, I.E MinLength or Required. Its constructor
+// does so once the options are applied, an input made without one, I.E &TextInput{MinLength: 3}, does so when it is
+// added to the stack.
+type constrained interface {
+	field
+	constrain()
+}
+
+// constrain builds the parse of an input that wasn't made by its constructor
+func constrain(element Executable) {
+	if c, ok := element.(constrained); ok && c.base().parse == nil {
+		c.constrain()
+	}
+}
+
+// validate checks an answer before the prompt is closed, an input that has no parse, I.E a FileInput made without
+// Input.File, takes anything
+func (b *InputBase) validate(v any) error {
+	if b.parse == nil {
+		return nil
+	}
+	_, err := b.parse(v)
+	return err
+}
+
+// prompt adds the input to the stack and returns its answer as a T, or its default when it times out
+func prompt[T any](io *Io, input field) (T, error) {
+	var zero T
+	base := input.base()
+	if base.err != nil {
+		return zero, base.err
+	}
+	v, err := io.addToStack(input, base.timeout)
+	if errors.Is(err, ErrInputTimeout) {
+		if d, ok := base.initial.(T); ok {
+			return d, nil
+		}
+	}
+	if err != nil {
+		return zero, err
+	}
+	t, err := base.parse(v)
+	if err != nil {
+		return zero, err
+	}
+	return t.(T), nil
+}
+
+// parseString checks the answer is a string with a length between the min and max, when they are set
+func parseString(base *InputBase, minLength, maxLength int) func(any) (string, error) {
+	return func(v any) (string, error) {
+		s, ok := v.(string)
+		if !ok {
+			return "", fmt.Errorf("expected string, got %T", v)
+		}
+		if strings.TrimSpace(s) == "" {
+			if base.Required {
+				return "", ErrRequired
+			}
+			return s, nil
+		}
+		n := utf8.RuneCountInString(s)
+		if minLength > 0 && n < minLength {
+			return "", fmt.Errorf("must be at least %d characters", minLength)
+		}
+		if maxLength > 0 && n > maxLength {
+			return "", fmt.Errorf("must be at most %d characters", maxLength)
+		}
+		return s, nil
+	}
+}
+
+func parseEmail(base *InputBase) func(any) (string, error) {
+	parse := parseString(base, 0, 0)
+	return func(v any) (string, error) {
+		s, err := parse(v)
+		if err != nil || s == "" {
+			return s, err
+		}
+		addr, err := mail.ParseAddress(s)
+		if err != nil || addr.Address != s {
+			return "", errors.New("must be an email address")
+		}
+		return s, nil
+	}
+}
+
+func parseURL(base *InputBase) func(any) (string, error) {
+	parse := parseString(base, 0, 0)
+	return func(v any) (string, error) {
+		s, err := parse(v)
+		if err != nil || s == "" {
+			return s, err
+		}
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return "", errors.New("must be a URL like https://example.com")
+		}
+		return s, nil
+	}
+}
+
+// parseNumber takes the number as the client sends it, as a string, or as a JSON number when it comes from a checkpoint
+func parseNumber(input *NumberInput) func(any) (int, error) {
+	base := &input.InputBase
+	return func(v any) (int, error) {
+		var n int
+		switch v := v.(type) {
+		case string:
+			if strings.TrimSpace(v) == "" {
+				if base.Required {
+					return 0, ErrRequired
+				}
+				return 0, nil
+			}
+			i, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return 0, errors.New("must be a whole number")
+			}
+			n = i
+		case float64:
+			if v != math.Trunc(v) {
+				return 0, errors.New("must be a whole number")
+			}
+			n = int(v)
+		default:
+			return 0, fmt.Errorf("expected number, got %T", v)
+		}
+		if (input.min != nil || input.Min != 0) && float64(n) < input.Min {
+			return 0, fmt.Errorf("must be at least %s", formatFloat(input.Min))
+		}
+		if (input.max != nil || input.Max != 0) && float64(n) > input.Max {
+			return 0, fmt.Errorf("must be at most %s", formatFloat(input.Max))
+		}
+		return n, nil
+	}
+}
+
+func parseSlider(s *SliderInput) func(any) (float64, error) {
+	return func(v any) (float64, error) {
+		f, ok := v.(float64)
+		if !ok {
+			return 0, fmt.Errorf("expected number, got %T", v)
+		}
+		if f < s.Min || f > s.Max {
+			return 0, fmt.Errorf("must be between %s and %s", formatFloat(s.Min), formatFloat(s.Max))
+		}
+		// a little leeway for the float the client worked it out with
+		if s.Step > 0 && math.Abs(math.Remainder(f-s.Min, s.Step)) > s.Step*1e-9 {
+			return 0, fmt.Errorf("must be in steps of %s from %s", formatFloat(s.Step), formatFloat(s.Min))
+		}
+		return f, nil
+	}
+}
+
+// parseTime parses the answer with the layout, min and max are in the same layout so they compare as strings
+func parseTime(base *InputBase, layout string, min, max string) func(any) (time.Time, error) {
+	return func(v any) (time.Time, error) {
+		s, ok := v.(string)
+		if !ok {
+			return time.Time{}, fmt.Errorf("expected string, got %T", v)
+		}
+		if s == "" {
+			if base.Required {
+				return time.Time{}, ErrRequired
+			}
+			return time.Time{}, nil
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("must be formatted like %s", layout)
+		}
+		if min != "" && s < min {
+			return time.Time{}, fmt.Errorf("must be %s or later", min)
+		}
+		if max != "" && s > max {
+			return time.Time{}, fmt.Errorf("must be %s or earlier", max)
+		}
+		return t, nil
+	}
+}
+
+func parseBoolean(v any) (bool, error) {
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected boolean, got %T", v)
+	}
+	return b, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,234 @@
+package bff
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rejected gives the prompt a single answer and checks it is sent back as a validationError, the message is returned
+func rejected(t *testing.T, answer any, prompt func(io *Io) error) string {
+	t.Helper()
+	input := make(chan Message, 1)
+	output := make(chan Message, 2)
+	io := NewIo(input, output)
+
+	input <- Message{Type: "input", Data: answer}
+	// closed so the prompt gives up rather than waiting for a better answer
+	close(input)
+	err := prompt(io)
+	if err == nil {
+		t.Errorf("expected %v to be rejected", answer)
+		return ""
+	}
+	<-output
+	select {
+	case m := <-output:
+		if m.Type != "validationError" {
+			t.Errorf("expected a validationError for %v, got %+v", answer, m)
+			return ""
+		}
+		return m.Data.(string)
+	default:
+		t.Errorf("expected a validationError for %v, got %v", answer, err)
+		return ""
+	}
+}
+
+func TestText_Validation(t *testing.T) {
+	input := make(chan Message)
+	output := make(chan Message)
+	io := NewIo(input, output)
+
+	noAdmins := func(s string) error {
+		if strings.EqualFold(s, "admin") {
+			return errors.New("admin is taken")
+		}
+		return nil
+	}
+	done := make(chan string)
+	go func() {
+		s, err := io.Input.Text("Username", WithRequired(true), WithMinLength(3), WithMaxLength(8), WithValidator(noAdmins))
+		if err != nil {
+			t.Error(err)
+		}
+		done <- s
+	}()
+
+	m := <-output
+	if m.Type != "textInput" || m.Data.(*TextInput).MinLength != 3 || m.Data.(*TextInput).MaxLength != 8 {
+		t.Fatalf("expected a textInput with the lengths set, got %+v", m)
+	}
+	for answer, expected := range map[string]string{
+		"   ":        ErrRequired.Error(),
+		"al":         "must be at least 3 characters",
+		"alexandria": "must be at most 8 characters",
+		"Admin":      "admin is taken",
+	} {
+		input <- Message{Type: "input", Data: answer}
+		m = <-output
+		if m.Type != "validationError" || m.Data != expected {
+			t.Errorf("expected %q for %q, got %+v", expected, answer, m)
+		}
+	}
+	input <- Message{Type: "input", Data: "alex"}
+	if s := <-done; s != "alex" {
+		t.Errorf("expected alex, got %q", s)
+	}
+}
+
+func TestAddToStack_Validation(t *testing.T) {
+	// inputs made without their constructor are held to their fields
+	stack := func(element Executable) func(io *Io) error {
+		return func(io *Io) error {
+			_, err := io.AddToStack(element)
+			return err
+		}
+	}
+	name := &TextInput{InputBase: InputBase{Label: "Name", Required: true}, MinLength: 3}
+	if msg := rejected(t, " ", stack(name)); msg != ErrRequired.Error() {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if msg := rejected(t, "al", stack(name)); msg != "must be at least 3 characters" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	seats := &NumberInput{InputBase: InputBase{Label: "Seats"}, Min: 1, Max: 10}
+	if msg := rejected(t, 11.0, stack(seats)); msg != "must be at most 10" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	// so is an input whose fields were changed after it was made
+	seats = NewNumberInput("Seats", WithMax(10))
+	seats.Min = 2
+	if msg := rejected(t, 1.0, stack(seats)); msg != "must be at least 2" {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestNumber_Validation(t *testing.T) {
+	number := func(options ...NumberOption) func(io *Io) error {
+		return func(io *Io) error {
+			_, err := io.Input.Number("Seats", options...)
+			return err
+		}
+	}
+	if msg := rejected(t, "eleven", number()); msg != "must be a whole number" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if msg := rejected(t, "", number(WithRequired(true))); msg != ErrRequired.Error() {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if msg := rejected(t, "0", number(WithMin(1), WithMax(10))); msg != "must be at least 1" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if msg := rejected(t, "11", number(WithMin(1), WithMax(10))); msg != "must be at most 10" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	odd := WithValidator(func(n int) error {
+		if n%2 == 0 {
+			return errors.New("must be odd")
+		}
+		return nil
+	})
+	if msg := rejected(t, "4", number(odd)); msg != "must be odd" {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	input := make(chan Message, 1)
+	output := make(chan Message, 1)
+	io := NewIo(input, output)
+	input <- Message{Type: "input", Data: "7"}
+	n, err := io.Input.Number("Seats", WithMin(1), WithMax(10), odd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := <-output; m.Data.(*NumberInput).Max != 10 {
+		t.Errorf("expected the max to be sent to the client, got %+v", m.Data)
+	}
+	if n != 7 {
+		t.Errorf("expected 7, got %d", n)
+	}
+}
+
+func TestInput_BuiltInChecks(t *testing.T) {
+	email := func(io *Io) error {
+		_, err := io.Input.Email("Email")
+		return err
+	}
+	rejected(t, "not an email", email)
+	rejected(t, "Bob <bob@example.com>", email)
+
+	url := func(io *Io) error {
+		_, err := io.Input.URL("Website")
+		return err
+	}
+	rejected(t, "example.com", url)
+
+	rejected(t, 11.0, func(io *Io) error {
+		_, err := io.Input.Slider("Volume", 0, 10)
+		return err
+	})
+	rejected(t, "yesterday", func(io *Io) error {
+		_, err := io.Input.Date("Due")
+		return err
+	})
+	rejected(t, "25:00", func(io *Io) error {
+		_, err := io.Input.Time("Start")
+		return err
+	})
+
+	// an optional date can be left empty
+	input := make(chan Message, 1)
+	output := make(chan Message, 1)
+	io := NewIo(input, output)
+	input <- Message{Type: "input", Data: ""}
+	d, err := io.Input.Date("Due")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Equal(time.Time{}) {
+		t.Errorf("expected no date, got %v", d)
+	}
+}
+
+func TestWithValidator_WrongType(t *testing.T) {
+	io := NewIo(make(chan Message), make(chan Message))
+	_, err := io.Input.Text("Name", WithValidator(func(n int) error { return nil }))
+	if err == nil {
+		t.Error("expected a validator for ints to be refused by a text input")
+	}
+}
+
+func TestReplay_NoLongerValid(t *testing.T) {
+	input := make(chan Message, 1)
+	output := make(chan Message, 3)
+	io := NewIo(input, output)
+	io.checkpoint = &Checkpoint{Session: "s", Run: "r", Answers: []any{"al", "whatever came next"}}
+	io.store = NewMemoryStore()
+
+	// the answer was fine when it was given, the handler has changed since so the user is asked again
+	input <- Message{Type: "input", Data: "alice"}
+	name, err := io.Input.Text("Username", WithMinLength(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := <-output; name != "alice" || m.Type != "textInput" || len(output) != 0 {
+		t.Errorf("expected the username to be asked for again, got %q after %+v", name, m)
+	}
+	if len(io.checkpoint.Answers) != 1 || io.checkpoint.Answers[0] != "alice" {
+		t.Errorf("expected the answers after it to be thrown away, got %v", io.checkpoint.Answers)
+	}
+	// files don't survive a restart, the user uploads them again
+	io = NewIo(input, output)
+	io.uploads = newUploads()
+	io.checkpoint = &Checkpoint{Session: "s", Run: "r", Answers: []any{[]any{0.0}}}
+	io.store = NewMemoryStore()
+	input <- Message{Type: "input", Data: []any{}}
+	files, err := io.Input.File("Invoice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := <-output; m.Type != "fileInput" || len(files) != 0 {
+		t.Errorf("expected the file to be asked for again, got %v after %+v", files, m)
+	}
+}
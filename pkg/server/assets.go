@@ -3,6 +3,7 @@ package server
 import (
 	"embed"
 	"fmt"
+	"html"
 	"io"
 	"io/fs"
 	"log/slog"
@@ -18,7 +19,9 @@ import (
 //go:embed dist
 var frontend embed.FS
 
-func serveReactIndex(prefix string) http.Handler {
+// serveReactIndex serves the react app with the CSRF token of the browser in it
+func (s *Server) serveReactIndex() http.Handler {
+	prefix := s.handlerPrefix
 	var viteProxy http.Handler
 	if development == "true" {
 		viteDevServerURL, _ := url.Parse("http://localhost:5173") // Default Vite dev server address
@@ -30,38 +33,36 @@ func serveReactIndex(prefix string) http.Handler {
 		slog.Error("failed to open index.html", "err", err)
 		panic(err)
 	}
-	rdr, ok := fp.(io.ReadSeeker)
-	if !ok {
-		slog.Error("failed to open index.html and coerce it in to a readseeker")
-		panic("failed to open index.html and coerce it in to a readseeker")
-	}
 	stat, err := fp.Stat()
 	if err != nil {
 		slog.Error("failed to stat index.html", "err", err)
 		panic(err)
 	}
+	srcContent, err := io.ReadAll(fp)
+	if err != nil {
+		slog.Error("failed to read index.html", "err", err)
+		panic(err)
+	}
+	content := string(srcContent)
 	if prefix != "" {
 		slog.Debug("rewriting index.html with prefix", "prefix", prefix)
-		srcContent, err := io.ReadAll(rdr)
-		if err != nil {
-			slog.Error("failed to read index.html", "err", err)
-			panic(err)
-		}
 		// rewrite the index.html with the prefix
 		srcRegex := regexp.MustCompile(`(src|href)="(/[^"]*)"`)
 		// Replace the asset sources with prefixed versions
-		modifiedContent := srcRegex.ReplaceAllStringFunc(string(srcContent), func(match string) string {
+		content = srcRegex.ReplaceAllStringFunc(content, func(match string) string {
 			parts := srcRegex.FindStringSubmatch(match)
 			if len(parts) == 3 {
 				return fmt.Sprintf(`%s="%s%s"`, parts[1], prefix, parts[2])
 			}
 			return match
 		})
-		rdr = io.ReadSeeker(strings.NewReader(modifiedContent))
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// insert the prefix into the index.html
-		http.ServeContent(w, r, "index.html", stat.ModTime(), rdr)
+		// the page has the token of the browser in it, so it can't be cached
+		w.Header().Set("Cache-Control", "no-store")
+		meta := fmt.Sprintf(`<meta name="bff-csrf" content="%s" />`, html.EscapeString(s.csrfToken(w, r)))
+		page := strings.Replace(content, "</head>", meta+"</head>", 1)
+		http.ServeContent(w, r, "index.html", stat.ModTime(), strings.NewReader(page))
 	})
 
 }
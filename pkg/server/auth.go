@@ -0,0 +1,307 @@
+package server
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ebuckley/bff/pkg/bff"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Authenticator works out who made a request, every request to the server goes through it when one is set
+type Authenticator interface {
+	// Authenticate returns the user making the request, or ErrUnauthenticated when they haven't proven who they are
+	Authenticate(r *http.Request) (*bff.User, error)
+}
+
+// Challenger is implemented by authenticators that can ask the user to sign in, I.E by asking the browser for a
+// password or redirecting to a login page. Requests that fail to authenticate get a plain 401 otherwise.
+type Challenger interface {
+	Challenge(w http.ResponseWriter, r *http.Request)
+}
+
+// WithAuthenticator makes everyone sign in, handlers can find out who is running them with bff.UserFromContext
+func WithAuthenticator(auth Authenticator) Serveropts {
+	return func(s *Server) {
+		s.auth = auth
+	}
+}
+
+// authenticate puts the user in the context of the request, or turns the request away
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := s.auth.Authenticate(r)
+		if err != nil {
+			if !errors.Is(err, ErrUnauthenticated) {
+				slog.Error("failed to authenticate request", "path", r.URL.Path, "err", err)
+			}
+			if c, ok := s.auth.(Challenger); ok {
+				c.Challenge(w, r)
+				return
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(bff.ContextWithUser(r.Context(), user)))
+	})
+}
+
+// ProxyAuthenticator trusts the headers set by a reverse proxy that has already signed the user in, I.E oauth2-proxy.
+// The headers are only believed when the request comes from one of the trusted proxies.
+type ProxyAuthenticator struct {
+	UserHeader  string
+	NameHeader  string
+	EmailHeader string
+	// GroupsHeader is a comma separated list of groups, they become the roles of the user
+	GroupsHeader string
+
+	trusted []netip.Prefix
+}
+
+// NewProxyAuthenticator trusts requests from the given addresses or CIDR ranges I.E "10.0.0.0/8" or "127.0.0.1"
+func NewProxyAuthenticator(trusted ...string) (*ProxyAuthenticator, error) {
+	p := &ProxyAuthenticator{
+		UserHeader:   "X-Forwarded-User",
+		NameHeader:   "X-Forwarded-Preferred-Username",
+		EmailHeader:  "X-Forwarded-Email",
+		GroupsHeader: "X-Forwarded-Groups",
+	}
+	for _, t := range trusted {
+		prefix, err := netip.ParsePrefix(t)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(t)
+			if addrErr != nil {
+				return nil, fmt.Errorf("trusted proxy %q is not an address or CIDR range", t)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		p.trusted = append(p.trusted, prefix.Masked())
+	}
+	if len(p.trusted) == 0 {
+		return nil, errors.New("at least one trusted proxy is needed")
+	}
+	return p, nil
+}
+
+func (p *ProxyAuthenticator) Authenticate(r *http.Request) (*bff.User, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !p.isTrusted(addr.Unmap()) {
+		return nil, fmt.Errorf("%w: request did not come through a trusted proxy", ErrUnauthenticated)
+	}
+	user := &bff.User{
+		ID:    r.Header.Get(p.UserHeader),
+		Name:  r.Header.Get(p.NameHeader),
+		Email: r.Header.Get(p.EmailHeader),
+	}
+	if user.ID == "" {
+		user.ID = user.Email
+	}
+	for _, group := range strings.Split(r.Header.Get(p.GroupsHeader), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			user.Roles = append(user.Roles, group)
+		}
+	}
+	if user.ID == "" {
+		return nil, fmt.Errorf("%w: the proxy did not say who the user is", ErrUnauthenticated)
+	}
+	return user, nil
+}
+
+func (p *ProxyAuthenticator) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range p.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// HtpasswdAuthenticator checks HTTP basic auth against a htpasswd file, the passwords must be bcrypt (htpasswd -B) or
+// SHA1 (htpasswd -s) hashes
+type HtpasswdAuthenticator struct {
+	Realm string
+	// Roles are the roles of each user, htpasswd files have nowhere to keep them
+	Roles map[string][]string
+
+	users map[string]string
+}
+
+// NewHtpasswdAuthenticator loads the users from the htpasswd file at path
+func NewHtpasswdAuthenticator(path string) (*HtpasswdAuthenticator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening htpasswd file: %w", err)
+	}
+	defer f.Close()
+
+	h := &HtpasswdAuthenticator{Realm: "bff", users: make(map[string]string)}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, hash, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("htpasswd line %d: expected user:hash", line)
+		}
+		if !strings.HasPrefix(hash, "$2") && !strings.HasPrefix(hash, "{SHA}") {
+			return nil, fmt.Errorf("htpasswd line %d: unsupported hash for %s, use bcrypt (htpasswd -B)", line, name)
+		}
+		h.users[name] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading htpasswd file: %w", err)
+	}
+	return h, nil
+}
+
+func (h *HtpasswdAuthenticator) Authenticate(r *http.Request) (*bff.User, error) {
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	hash, ok := h.users[name]
+	if !ok || !checkPassword(hash, password) {
+		return nil, fmt.Errorf("%w: wrong user or password", ErrUnauthenticated)
+	}
+	return &bff.User{ID: name, Name: name, Roles: h.Roles[name]}, nil
+}
+
+// Challenge asks the browser for a user and password
+func (h *HtpasswdAuthenticator) Challenge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, h.Realm))
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+func checkPassword(hash string, password string) bool {
+	if sha, ok := strings.CutPrefix(hash, "{SHA}"); ok {
+		sum := sha1.Sum([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(base64.StdEncoding.EncodeToString(sum[:])), []byte(sha)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// DefaultCookieTTL is how long a signed session cookie is good for
+const DefaultCookieTTL = 12 * time.Hour
+
+// CookieAuthenticator keeps the user in a signed cookie. The app signs people in its own way, I.E with a login
+// page or a single sign on callback, then calls SignIn to hand them the cookie.
+type CookieAuthenticator struct {
+	// Name is the name of the cookie
+	Name string
+	TTL  time.Duration
+	// LoginURL is where people without a cookie are sent, with the page they wanted in the next parameter. They get a
+	// 401 when it isn't set.
+	LoginURL string
+
+	secret []byte
+	now    func() time.Time
+}
+
+// cookieClaims is what is signed in to the cookie
+type cookieClaims struct {
+	User    *bff.User `json:"user"`
+	Expires int64     `json:"exp"`
+}
+
+// NewCookieAuthenticator signs cookies with the secret, it needs to be at least 32 random bytes and the same on every
+// server behind the same address
+func NewCookieAuthenticator(secret []byte) (*CookieAuthenticator, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("cookie secret needs to be at least 32 bytes")
+	}
+	return &CookieAuthenticator{Name: "bff_session", TTL: DefaultCookieTTL, secret: secret}, nil
+}
+
+// SignIn gives the user a cookie that proves who they are until it expires
+func (c *CookieAuthenticator) SignIn(w http.ResponseWriter, user *bff.User) error {
+	expires := c.clock().Add(c.TTL)
+	payload, err := json.Marshal(cookieClaims{User: user, Expires: expires.Unix()})
+	if err != nil {
+		return err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.Name,
+		Value:    encoded + "." + c.sign(encoded),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// SignOut removes the cookie
+func (c *CookieAuthenticator) SignOut(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: c.Name, Path: "/", MaxAge: -1, HttpOnly: true, Secure: true, SameSite: http.SameSiteLaxMode})
+}
+
+func (c *CookieAuthenticator) Authenticate(r *http.Request) (*bff.User, error) {
+	cookie, err := r.Cookie(c.Name)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	encoded, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(c.sign(encoded))) {
+		return nil, fmt.Errorf("%w: bad cookie signature", ErrUnauthenticated)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad cookie", ErrUnauthenticated)
+	}
+	var claims cookieClaims
+	err = json.Unmarshal(payload, &claims)
+	if err != nil || claims.User == nil {
+		return nil, fmt.Errorf("%w: bad cookie", ErrUnauthenticated)
+	}
+	if c.clock().Unix() >= claims.Expires {
+		return nil, fmt.Errorf("%w: cookie expired", ErrUnauthenticated)
+	}
+	return claims.User, nil
+}
+
+// Challenge sends the user to the login page
+func (c *CookieAuthenticator) Challenge(w http.ResponseWriter, r *http.Request) {
+	if c.LoginURL == "" || r.Method != http.MethodGet || r.Header.Get("Upgrade") != "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	http.Redirect(w, r, c.LoginURL+"?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+}
+
+func (c *CookieAuthenticator) sign(encoded string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (c *CookieAuthenticator) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
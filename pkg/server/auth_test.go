@@ -0,0 +1,286 @@
+package server
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket/wsjson"
+	"github.com/ebuckley/bff/pkg/bff"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestProxyAuthenticator(t *testing.T) {
+	auth, err := NewProxyAuthenticator("10.0.0.0/8", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := func(remote string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remote
+		r.Header.Set("X-Forwarded-Email", "gopher@example.com")
+		return r
+	}
+	user, err := auth.Authenticate(req("10.1.2.3:4567"))
+	if err != nil || user.ID != "gopher@example.com" {
+		t.Errorf("expected the user from the headers, got %+v %v", user, err)
+	}
+	_, err = auth.Authenticate(req("192.168.1.1:4567"))
+	if !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected headers from an untrusted address to be ignored, got %v", err)
+	}
+	_, err = NewProxyAuthenticator("not an address")
+	if err == nil {
+		t.Error("expected a bad trusted proxy to be rejected")
+	}
+}
+
+func TestHtpasswdAuthenticator(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha1.Sum([]byte("swordfish"))
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	content := "# users\nalice:" + string(hash) + "\nbob:{SHA}" + base64.StdEncoding.EncodeToString(sum[:]) + "\n"
+	err = os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := NewHtpasswdAuthenticator(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		user, password string
+		ok             bool
+	}{
+		{"alice", "hunter2", true},
+		{"bob", "swordfish", true},
+		{"alice", "swordfish", false},
+		{"eve", "hunter2", false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.SetBasicAuth(c.user, c.password)
+		user, err := auth.Authenticate(r)
+		if c.ok && (err != nil || user.ID != c.user) {
+			t.Errorf("expected %s to sign in, got %v", c.user, err)
+		}
+		if !c.ok && !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("expected %s to be turned away, got %v", c.user, err)
+		}
+	}
+
+	err = os.WriteFile(path, []byte("carol:$apr1$abc$def\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewHtpasswdAuthenticator(path)
+	if err == nil {
+		t.Error("expected unsupported hashes to be rejected")
+	}
+}
+
+func TestCookieAuthenticator(t *testing.T) {
+	auth, err := NewCookieAuthenticator([]byte(strings.Repeat("s", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	auth.now = func() time.Time { return now }
+	auth.LoginURL = "/login"
+
+	w := httptest.NewRecorder()
+	err = auth.SignIn(w, &bff.User{ID: "alice", Name: "Alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := w.Result().Cookies()[0]
+	req := func(c *http.Cookie) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/a/hello", nil)
+		r.AddCookie(c)
+		return r
+	}
+	user, err := auth.Authenticate(req(cookie))
+	if err != nil || user.Name != "Alice" {
+		t.Errorf("expected alice to be signed in, got %+v %v", user, err)
+	}
+
+	tampered := *cookie
+	tampered.Value = strings.Replace(cookie.Value, cookie.Value[:4], "AAAA", 1)
+	if _, err := auth.Authenticate(req(&tampered)); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected a tampered cookie to be rejected, got %v", err)
+	}
+	now = now.Add(DefaultCookieTTL)
+	if _, err := auth.Authenticate(req(cookie)); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected an expired cookie to be rejected, got %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	auth.Challenge(w, httptest.NewRequest(http.MethodGet, "/a/hello", nil))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login?next=%2Fa%2Fhello" {
+		t.Errorf("expected a redirect to the login page, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestServer_WithAuthenticator(t *testing.T) {
+	auth, err := NewProxyAuthenticator("192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bffInstance := bff.New()
+	err = bffInstance.RegisterAction("whoami", func(ctx context.Context, io *bff.Io) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(bffInstance, WithAuthenticator(auth))
+
+	// httptest requests come from 192.0.2.1
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Forwarded-User", "gopher")
+	r.Header.Set("X-Forwarded-Preferred-Username", "Gopher")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Signed in as Gopher") {
+		t.Errorf("expected the signed in user on the index, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a request without a user to be turned away, got %d", w.Code)
+	}
+}
+
+func TestServer_Roles(t *testing.T) {
+	auth, err := NewProxyAuthenticator("192.0.2.0/24", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the auditors can read every run, but only of the actions they are allowed
+	bffInstance := bff.New(bff.WithRunStore(bff.NewMemoryRunStore()), bff.WithRunPolicy(bff.RolesRunPolicy("audit")))
+	err = bffInstance.RegisterAction("hello", func(ctx context.Context, io *bff.Io) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bffInstance.RegisterAction("launch nukes", func(ctx context.Context, io *bff.Io) error {
+		return nil
+	}, bff.WithSlug("nuke"), bff.WithRoles("admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(bffInstance, WithAuthenticator(auth))
+
+	get := func(path string, groups string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("X-Forwarded-User", "gopher")
+		r.Header.Set("X-Forwarded-Groups", groups)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, r)
+		return w
+	}
+	if body := get("/", "support").Body.String(); strings.Contains(body, "/a/nuke") || !strings.Contains(body, "/a/hello") {
+		t.Errorf("expected only the actions the user can run on the index, got %s", body)
+	}
+	if body := get("/", "support, admin").Body.String(); !strings.Contains(body, "/a/nuke") {
+		t.Errorf("expected admins to see the nuke action, got %s", body)
+	}
+	if w := get("/a/nuke", "support"); w.Code != http.StatusForbidden {
+		t.Errorf("expected the action page to be forbidden, got %d", w.Code)
+	}
+
+	admin := bff.ContextWithUser(context.Background(), &bff.User{ID: "root", Roles: []string{"admin"}})
+	err = bffInstance.ExecuteAction(admin, "nuke", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := bffInstance.Runs(admin, bff.RunFilter{Action: "nuke"})
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected the admin to see the nuke run, got %v %v", runs, err)
+	}
+	if body := get("/runs?action=nuke", "support, audit").Body.String(); strings.Contains(body, runs[0].ID) {
+		t.Errorf("expected the runs of an action the user can't run not to be listed, got %s", body)
+	}
+	if w := get("/runs/"+runs[0].ID, "support, audit"); w.Code != http.StatusNotFound {
+		t.Errorf("expected a run of an action the user can't run to be not found, got %d", w.Code)
+	}
+	if w := get("/runs/"+runs[0].ID, "admin, audit"); w.Code != http.StatusOK {
+		t.Errorf("expected the run to be readable by an auditor who can run it, got %d", w.Code)
+	}
+
+	// starting the action by hand over the socket of another action doesn't get around the roles
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	c := openPage(t, ts.URL+"/a/hello", http.Header{"X-Forwarded-User": {"gopher"}}).dial(t, "")
+	defer c.CloseNow()
+	readMessage(t, c) // session
+	err = wsjson.Write(context.Background(), c, bff.Message{Type: "start", Data: "nuke"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := readMessage(t, c)
+	if m.Type != "error" || m.Data != bff.ErrForbidden.Error() {
+		t.Errorf("expected the start to be forbidden, got %+v", m)
+	}
+}
+
+func TestServer_RunsBelongToTheirUser(t *testing.T) {
+	auth, err := NewProxyAuthenticator("192.0.2.0/24", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bffInstance := bff.New(bff.WithRunStore(bff.NewMemoryRunStore()), bff.WithRunPolicy(bff.RolesRunPolicy("audit")))
+	err = bffInstance.RegisterAction("greet", func(ctx context.Context, io *bff.Io) error {
+		_, err := io.Input.Text("What is your name?")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := make(chan bff.Message, 1)
+	input <- bff.Message{Type: "input", Data: "alice's secret"}
+	ctx := bff.ContextWithUser(context.Background(), &bff.User{ID: "alice"})
+	err = bffInstance.ExecuteAction(ctx, "greet", input, make(chan bff.Message, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := bffInstance.Runs(ctx, bff.RunFilter{})
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected alice to see her run, got %v %v", runs, err)
+	}
+	server := NewServer(bffInstance, WithAuthenticator(auth))
+
+	get := func(path string, user string, groups string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("X-Forwarded-User", user)
+		r.Header.Set("X-Forwarded-Groups", groups)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, r)
+		return w
+	}
+	if body := get("/runs", "mallory", "").Body.String(); strings.Contains(body, runs[0].ID) {
+		t.Errorf("expected someone else's run not to be listed, got %s", body)
+	}
+	if w := get("/runs/"+runs[0].ID, "mallory", ""); w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("expected someone else's run to be not found, got %d", w.Code)
+	}
+	if w := get("/runs/"+runs[0].ID, "alice", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("expected alice to read her own run, got %d", w.Code)
+	}
+	if w := get("/runs/"+runs[0].ID, "auditor", "audit"); w.Code != http.StatusOK {
+		t.Errorf("expected a role the policy allows to read every run, got %d", w.Code)
+	}
+}
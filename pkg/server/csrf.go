@@ -0,0 +1,49 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+)
+
+// csrfCookie holds the token the react app has to send back when it opens a socket or uploads a file. The token is
+// embedded in the page as well, another site can get the browser to send the cookie but can't read the page to find
+// out what the token is.
+const csrfCookie = "bff_csrf"
+
+// csrfHeader is how uploads send the token
+const csrfHeader = "X-BFF-CSRF"
+
+// csrfToken returns the token of the browser, giving it one when it doesn't have one yet
+func (s *Server) csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(csrfCookie); err == nil && len(c.Value) == 43 {
+		return c.Value
+	}
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	token := base64.RawURLEncoding.EncodeToString(b)
+	path := s.handlerPrefix + "/"
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     path,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+// checkCSRF makes sure the token sent with the request matches the cookie
+func checkCSRF(r *http.Request, token string) bool {
+	if development == "true" {
+		// vite serves the page in development, so there is no token in it
+		return true
+	}
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.Value), []byte(token)) == 1
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ebuckley/bff/pkg/bff"
+)
+
+// runsLimit is how many runs the runs page lists
+const runsLimit = 200
+
+// runs lists the most recent runs, ?action= narrows them down to one action
+func (s *Server) runs(w http.ResponseWriter, r *http.Request) {
+	action := r.URL.Query().Get("action")
+	runs, err := s.BFF.Runs(r.Context(), bff.RunFilter{Action: action, Limit: runsLimit})
+	if err != nil {
+		slog.Error("failed to list runs", "err", err)
+		http.Error(w, "failed to list runs", http.StatusInternalServerError)
+		return
+	}
+	state := struct {
+		Prefix string
+		Action string
+		Runs   []*bff.Run
+	}{
+		Prefix: s.handlerPrefix,
+		Action: action,
+		Runs:   runs,
+	}
+	err = runsPage.Execute(w, state)
+	if err != nil {
+		slog.Error("failed to render runs", "err", err)
+	}
+}
+
+// run shows everything that happened in a run
+func (s *Server) run(w http.ResponseWriter, r *http.Request) {
+	run, err := s.BFF.Run(r.Context(), r.PathValue("id"))
+	if errors.Is(err, bff.ErrRunNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to load run", "run", r.PathValue("id"), "err", err)
+		http.Error(w, "failed to load run", http.StatusInternalServerError)
+		return
+	}
+	state := struct {
+		Prefix string
+		Run    *bff.Run
+	}{
+		Prefix: s.handlerPrefix,
+		Run:    run,
+	}
+	err = runPage.Execute(w, state)
+	if err != nil {
+		slog.Error("failed to render run", "err", err)
+	}
+}
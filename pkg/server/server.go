@@ -2,8 +2,10 @@ package server
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
 
 	"github.com/coder/websocket"
 	"github.com/coder/websocket/wsjson"
@@ -19,6 +21,9 @@ type Server struct {
 	assets        http.Handler
 	reactIndex    http.Handler
 	handlerPrefix string
+	auth          Authenticator
+	// originPatterns are the other origins allowed to open sockets, only the origin of the server is allowed otherwise
+	originPatterns []string
 }
 
 // NewServer creates a new server with the given BFF instance and handler prefix, the handler prefix is an optional
@@ -46,15 +51,24 @@ func (s *Server) setup() http.Handler {
 	// / -> index.html
 	// /a/{a} -> action (a react app)
 	// /a/{a}/ws -> websocket for action to do stuff
+	// /a/{a}/upload/{id} -> files for a file input
+	// /runs -> past runs of the actions
+	// /runs/{id} -> what happened in a run
 	s.assets = s.makeStaticServer()
-	s.reactIndex = serveReactIndex(s.handlerPrefix)
+	s.reactIndex = s.serveReactIndex()
 
 	mux.HandleFunc(s.handlerPrefix+"/", s.index)
-	mux.Handle(s.handlerPrefix+"/a/{action}", s.reactIndex)
+	mux.HandleFunc(s.handlerPrefix+"/a/{action}", s.actionPage)
 	mux.HandleFunc(s.handlerPrefix+"/a/{action}/ws", s.handleAction)
+	mux.HandleFunc("POST "+s.handlerPrefix+"/a/{action}/upload/{id}", s.handleUpload)
+	mux.HandleFunc("GET "+s.handlerPrefix+"/runs", s.runs)
+	mux.HandleFunc("GET "+s.handlerPrefix+"/runs/{id}", s.run)
 
 	s.mux = mux
-	return mux
+	if s.auth != nil {
+		s.mux = s.authenticate(mux)
+	}
+	return s.mux
 }
 func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	s.mux.ServeHTTP(writer, request)
@@ -66,14 +80,17 @@ func (s *Server) index(w http.ResponseWriter, r *http.Request) {
 		s.assets.ServeHTTP(w, r)
 		return
 	}
+	user, _ := bff.UserFromContext(r.Context())
 	state := struct {
 		Prefix  string
 		Heading string
+		User    *bff.User
 		Actions []*bff.Action
 	}{
 		Heading: "Actions",
 		Prefix:  s.handlerPrefix,
-		Actions: s.BFF.GetActions(),
+		User:    user,
+		Actions: s.BFF.ActionsFor(r.Context()),
 	}
 	err := index.Execute(w, state)
 	if err != nil {
@@ -81,13 +98,37 @@ func (s *Server) index(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to render index", http.StatusInternalServerError)
 	}
 }
+
+// actionPage serves the react app for the action, unless the user isn't allowed to run it
+func (s *Server) actionPage(w http.ResponseWriter, r *http.Request) {
+	if action, ok := s.BFF.Action(r.PathValue("action")); ok && !s.BFF.Allowed(r.Context(), action) {
+		http.Error(w, "you are not allowed to run this action", http.StatusForbidden)
+		return
+	}
+	s.reactIndex.ServeHTTP(w, r)
+}
+
 func (s *Server) handleAction(w http.ResponseWriter, r *http.Request) {
 	// is this a websocket upgrade request?
 	if r.Header.Get("Upgrade") != "websocket" {
 		http.Error(w, "expected websocket connection", http.StatusBadRequest)
 		return
 	}
-	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true, OriginPatterns: []string{"*"}})
+	if !checkCSRF(r, r.URL.Query().Get("csrf")) {
+		http.Error(w, "missing or bad csrf token", http.StatusForbidden)
+		return
+	}
+	version, err := clientVersion(r.URL.Query().Get("version"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// the loop checks every action it is asked to start too, this just saves opening a socket for nothing
+	if action, ok := s.BFF.Action(r.PathValue("action")); ok && !s.BFF.Allowed(r.Context(), action) {
+		http.Error(w, "you are not allowed to run this action", http.StatusForbidden)
+		return
+	}
+	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: s.originPatterns})
 	if err != nil {
 		http.Error(w, "could not open websocket connection", http.StatusBadRequest)
 		return
@@ -100,57 +141,125 @@ func (s *Server) handleAction(w http.ResponseWriter, r *http.Request) {
 	}(c)
 
 	// Set the context as needed. Use of r.Context() is not recommended
-	// to avoid surprising behavior (see http.Hijacker).
-	ctx := context.Background()
-
-	// this should be some kind of session registration.
-	// then we can drive the state somehow -- maybe just return with a list of actions for now?
+	// to avoid surprising behavior (see http.Hijacker), only its values are kept, I.E the user.
+	// The session lives on after this context is done, so the client can reconnect and carry on.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	defer cancel()
 
-	// now wait forever for more actions from the user
-	input := make(chan bff.Message)
-	output := make(chan bff.Message, 1)
+	// only a socket that opened gets a session, a failed upgrade mustn't start one or take one over
+	session, resumed, attachment := s.attach(ctx, r.URL.Query().Get("session"))
+	defer attachment.Detach()
 
-	go s.BFF.Loop(ctx, input, output)
+	// tell the client which session it is in so it can come back to it, then replay whatever it missed
+	state := sessionState{ID: session.ID, Resumed: resumed, Version: version}
+	err = send(ctx, c, version, bff.Message{Type: "session", ID: bff.NewMessageID(), Data: state})
+	if err != nil {
+		slog.Error("failed to send session: ", "err", err)
+		return
+	}
+	for _, m := range attachment.History {
+		err = send(ctx, c, version, m)
+		if err != nil {
+			slog.Error("failed to replay session history: ", "err", err)
+			return
+		}
+	}
 
 	//starts a thread for output
-	go func(ctx context.Context, output chan bff.Message, c *websocket.Conn) {
+	go func(ctx context.Context, attachment *bff.Attachment, c *websocket.Conn) {
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case v := <-output:
+			case <-attachment.Done:
+				// another connection took over the session, or the session is over
+				c.Close(websocket.StatusNormalClosure, "session detached")
+				cancel()
+				return
+			case v := <-attachment.Messages:
 				slog.Debug("sending anotha bff.Message: ", "type", v.Type, "payload", v.Data)
-				err = send(ctx, c, v)
+				err := send(ctx, c, version, v)
 				if err != nil {
 					slog.Error("failed to write display: ", "err", err)
 					c.Close(websocket.StatusInternalError, "failed to write display")
+					cancel()
 					return
 				}
 			}
 		}
-	}(ctx, output, c)
+	}(ctx, attachment, c)
 
 	for {
 		var v bff.Message
 		err = wsjson.Read(ctx, c, &v)
 		if err != nil {
-			slog.Error("failed to read from looped reader: ", "err", err)
+			slog.Debug("failed to read from looped reader: ", "err", err)
 			c.Close(websocket.StatusInternalError, "failed to read bff.Message")
 			return
 		}
-		select {
-		case <-ctx.Done():
-			slog.Debug("closing connection")
+		err = v.Check(version)
+		if err != nil {
+			err = send(ctx, c, version, bff.Reject(v, err))
+			if err != nil {
+				slog.Error("failed to reject message: ", "err", err)
+				return
+			}
+			continue
+		}
+		err = session.Send(ctx, v)
+		if err != nil {
+			slog.Debug("closing connection", "err", err)
 			c.Close(websocket.StatusNormalClosure, "")
 			return
-		case input <- v:
-			slog.Debug("received bff.Message: ", "type", v.Type, "payload", v.Data)
-			// carry on reading
 		}
+		slog.Debug("received bff.Message: ", "type", v.Type, "payload", v.Data)
 	}
 }
 
-func send(ctx context.Context, c *websocket.Conn, m bff.Message) error {
+// sessionState tells the client which session it is attached to, whether it picked up an existing one and the
+// protocol version the connection speaks
+type sessionState struct {
+	ID      string `json:"id"`
+	Resumed bool   `json:"resumed"`
+	Version int    `json:"version"`
+}
+
+// clientVersion is the protocol version to speak to a client asking for the given version, a client that doesn't ask
+// is from before messages were versioned
+func clientVersion(asked string) (int, error) {
+	if asked == "" {
+		return 0, nil
+	}
+	v, err := strconv.Atoi(asked)
+	if err != nil {
+		return 0, errors.New("version must be a whole number")
+	}
+	return bff.NegotiateVersion(v)
+}
+
+// attach connects to the session with the given ID when it is still running or can be resumed from a checkpoint,
+// otherwise it starts a new one
+func (s *Server) attach(ctx context.Context, id string) (*bff.Session, bool, *bff.Attachment) {
+	if id != "" {
+		session, err := s.BFF.ResumeSession(ctx, id)
+		if err == nil {
+			attachment, err := session.Attach()
+			if err == nil {
+				return session, true, attachment
+			}
+		} else if !errors.Is(err, bff.ErrSessionNotFound) {
+			slog.Error("failed to resume session", "session", id, "err", err)
+		}
+	}
+	session := s.BFF.NewSession(ctx)
+	// a brand new session has nobody else attached and can't have closed yet
+	attachment, _ := session.Attach()
+	return session, false, attachment
+}
+
+// send writes the message in the protocol version of the connection
+func send(ctx context.Context, c *websocket.Conn, version int, m bff.Message) error {
+	m.Version = version
 	slog.Debug("sending bff.Message: ", "type", m.Type, "payload", m.Data)
 	err := wsjson.Write(ctx, c, m)
 	if err != nil {
@@ -161,6 +270,14 @@ func send(ctx context.Context, c *websocket.Conn, m bff.Message) error {
 
 type Serveropts func(s *Server)
 
+// AllowedOrigins lets pages on other origins open sockets to the server, the patterns are matched against the host of
+// the origin I.E "admin.example.com" or "*.example.com". Only pages served by the server itself can by default.
+func AllowedOrigins(patterns ...string) Serveropts {
+	return func(s *Server) {
+		s.originPatterns = patterns
+	}
+}
+
 // Prefix lets you set a handler prefix I.E "/admin" for the server, this is useful if you want to run the server under a subpath
 func Prefix(prefix string) Serveropts {
 	return func(s *Server) {
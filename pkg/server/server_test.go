@@ -2,12 +2,17 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
 	"github.com/ebuckley/bff/pkg/bff"
 )
 
@@ -153,3 +158,490 @@ func TestServer_InvalidEnvironment(t *testing.T) {
 		t.Errorf("expected status NotFound, got %v", resp.Status)
 	}
 }
+
+func TestServer_ResumeSession(t *testing.T) {
+	bffInstance := bff.New()
+	err := bffInstance.RegisterAction("greet", func(ctx context.Context, io *bff.Io) error {
+		name, err := io.Input.Text("What is your name?")
+		if err != nil {
+			return err
+		}
+		io.Display.Heading("Hello, "+name, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(NewServer(bffInstance))
+	defer ts.Close()
+	ctx := context.Background()
+	page := openPage(t, ts.URL+"/a/greet", nil)
+
+	c := page.dial(t, "")
+	var state sessionState
+	m := readMessage(t, c)
+	if m.Type != "session" {
+		t.Fatalf("expected session message, got %s", m.Type)
+	}
+	decodeData(t, m, &state)
+	if state.Resumed {
+		t.Error("expected a new session")
+	}
+	err = wsjson.Write(ctx, c, bff.Message{Type: "start", Data: "greet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := readMessage(t, c); m.Type != "textInput" {
+		t.Fatalf("expected textInput, got %s", m.Type)
+	}
+	// drop the connection while the handler waits for an answer
+	_ = c.CloseNow()
+
+	c = page.dial(t, "session="+state.ID)
+	defer c.CloseNow()
+	var resumed sessionState
+	decodeData(t, readMessage(t, c), &resumed)
+	if !resumed.Resumed || resumed.ID != state.ID {
+		t.Fatalf("expected to resume session %s, got %+v", state.ID, resumed)
+	}
+	if m := readMessage(t, c); m.Type != "textInput" {
+		t.Fatalf("expected the pending textInput to be replayed, got %s", m.Type)
+	}
+	err = wsjson.Write(ctx, c, bff.Message{Type: "input", Data: "gopher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = readMessage(t, c)
+	var heading bff.HeadingDisplay
+	decodeData(t, m, &heading)
+	if heading.Text != "Hello, gopher" {
+		t.Errorf("expected the handler to carry on, got %+v", m)
+	}
+	if m := readMessage(t, c); m.Type != "done" {
+		t.Errorf("expected done, got %s", m.Type)
+	}
+}
+
+func TestServer_FailedUpgrade(t *testing.T) {
+	ts := httptest.NewServer(NewServer(testBff(t)))
+	defer ts.Close()
+	ctx := context.Background()
+	page := openPage(t, ts.URL+"/a/some-action", nil)
+
+	c := page.dial(t, "")
+	defer c.CloseNow()
+	var state sessionState
+	decodeData(t, readMessage(t, c), &state)
+
+	// an upgrade that is turned down doesn't take over the session
+	header := http.Header{"Cookie": {page.cookie.String()}, "Origin": {"https://evil.example"}}
+	url := "ws" + strings.TrimPrefix(page.url, "http") + "/ws?csrf=" + page.token + "&session=" + state.ID
+	_, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{HTTPHeader: header})
+	if err == nil {
+		t.Fatal("expected the upgrade from another origin to fail")
+	}
+	err = wsjson.Write(ctx, c, bff.Message{Type: "start", Data: "some-action"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := readMessage(t, c); m.Type != "done" {
+		t.Errorf("expected the session to still be attached, got %+v", m)
+	}
+}
+
+func TestServer_ProtocolVersion(t *testing.T) {
+	bffInstance := bff.New()
+	err := bffInstance.RegisterAction("greet", func(ctx context.Context, io *bff.Io) error {
+		name, err := io.Input.Text("What is your name?")
+		if err != nil {
+			return err
+		}
+		io.Display.Heading("Hello, "+name, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(NewServer(bffInstance))
+	defer ts.Close()
+	ctx := context.Background()
+	page := openPage(t, ts.URL+"/a/greet", nil)
+
+	c := page.dial(t, "version=7")
+	defer c.CloseNow()
+	var state sessionState
+	decodeData(t, readMessage(t, c), &state)
+	if state.Version != bff.ProtocolVersion {
+		t.Fatalf("expected a newer client to be spoken to in version %d, got %+v", bff.ProtocolVersion, state)
+	}
+	write := func(m bff.Message) {
+		t.Helper()
+		err := wsjson.Write(ctx, c, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(bff.Message{Type: "start", Data: "greet", ID: "1", Version: state.Version})
+	prompt := readMessage(t, c)
+	if prompt.Type != "textInput" || prompt.ID == "" || prompt.Version != state.Version {
+		t.Fatalf("expected a versioned prompt with an ID, got %+v", prompt)
+	}
+
+	write(bff.Message{Type: "input", Data: "gopher", ID: "2", Version: state.Version})
+	if m := readMessage(t, c); m.Type != "rejected" || m.InReplyTo != "2" || m.Data != bff.ErrNoReplyTo.Error() {
+		t.Errorf("expected an answer to nothing in particular to be rejected, got %+v", m)
+	}
+	write(bff.Message{Type: "input", Data: "gopher", ID: "3", InReplyTo: prompt.ID})
+	if m := readMessage(t, c); m.Type != "rejected" || m.InReplyTo != "3" {
+		t.Errorf("expected an unversioned message to be rejected, got %+v", m)
+	}
+	write(bff.Message{Type: "input", Data: "gopher", ID: "4", InReplyTo: prompt.ID, Version: state.Version})
+	m := readMessage(t, c)
+	var heading bff.HeadingDisplay
+	decodeData(t, m, &heading)
+	if heading.Text != "Hello, gopher" {
+		t.Errorf("expected the answer to be taken, got %+v", m)
+	}
+
+	for _, version := range []string{"-1", "latest"} {
+		url := "ws" + strings.TrimPrefix(page.url, "http") + "/ws?csrf=" + page.token + "&version=" + version
+		_, resp, err := websocket.Dial(ctx, url, &websocket.DialOptions{HTTPHeader: http.Header{"Cookie": {page.cookie.String()}}})
+		if err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected version %s to be refused, got %v", version, err)
+		}
+	}
+}
+
+func TestServer_Upload(t *testing.T) {
+	bffInstance := bff.New()
+	got := make(chan string, 1)
+	err := bffInstance.RegisterAction("upload", func(ctx context.Context, bio *bff.Io) error {
+		files, err := bio.Input.File("Upload some notes", bff.WithAccept(".txt"), bff.WithMaxFileSize(16))
+		if err != nil {
+			return err
+		}
+		f, err := files[0].Open()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		got <- files[0].Name + ":" + string(b)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(NewServer(bffInstance))
+	defer ts.Close()
+	ctx := context.Background()
+
+	page := openPage(t, ts.URL+"/a/upload", nil)
+	c := page.dial(t, "")
+	defer c.CloseNow()
+	readMessage(t, c) // session
+	err = wsjson.Write(ctx, c, bff.Message{Type: "start", Data: "upload"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := readMessage(t, c)
+	var input bff.FileInput
+	decodeData(t, m, &input)
+	if input.UploadID == "" || input.MaxSize != 16 {
+		t.Fatalf("expected a file input with an upload, got %+v", input)
+	}
+
+	post := func(query string, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/a/upload/upload/"+input.UploadID+"?"+query, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.AddCookie(page.cookie)
+		req.Header.Set(csrfHeader, page.token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := post("file=0&name=cat.png&type=image/png&size=5&offset=0", "meow!"); code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected a png to be rejected, got %d", code)
+	}
+	if code := post("file=0&name=big.txt&type=text/plain&size=17&offset=0", "too many bytes!!!"); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected a big file to be rejected, got %d", code)
+	}
+	if code := post("file=0&name=notes.txt&type=text/plain&size=11&offset=0", "HELLO "); code != http.StatusNoContent {
+		t.Fatalf("expected the first chunk to be accepted, got %d", code)
+	}
+	if code := post("file=0&name=notes.txt&type=text/plain&size=11&offset=3", "LO "); code != http.StatusConflict {
+		t.Errorf("expected a chunk out of order to be rejected, got %d", code)
+	}
+	// the upload failed partway, the client starts the file over
+	if code := post("file=0&name=notes.txt&type=text/plain&size=11&offset=0", "hello "); code != http.StatusNoContent {
+		t.Fatalf("expected the file to start over, got %d", code)
+	}
+	if code := post("file=0&name=notes.txt&type=text/plain&size=11&offset=6", "world"); code != http.StatusNoContent {
+		t.Fatalf("expected the last chunk to be accepted, got %d", code)
+	}
+	if code := post("file=1&name=more.txt&type=text/plain&size=5&offset=0", "notes"); code == http.StatusNoContent {
+		t.Errorf("expected a second file to be rejected, got %d", code)
+	}
+
+	err = wsjson.Write(ctx, c, bff.Message{Type: "input", Data: []int{0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s := <-got:
+		if s != "notes.txt:hello world" {
+			t.Errorf("expected the uploaded file, got %q", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler did not get the file")
+	}
+	if m := readMessage(t, c); m.Type != "done" {
+		t.Errorf("expected done, got %s", m.Type)
+	}
+	if code := post("file=0&name=notes.txt&type=text/plain&size=11&offset=0", "hello "); code != http.StatusNotFound {
+		t.Errorf("expected the upload to be gone once the action is over, got %d", code)
+	}
+}
+
+func TestServer_UploadChecksFiles(t *testing.T) {
+	bffInstance := bff.New()
+	err := bffInstance.RegisterAction("photos", func(ctx context.Context, bio *bff.Io) error {
+		_, err := bio.Input.File("Upload photos", bff.WithAccept("image/*"), bff.WithMultiple(), bff.WithMaxFiles(2))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(NewServer(bffInstance))
+	defer ts.Close()
+	ctx := context.Background()
+
+	page := openPage(t, ts.URL+"/a/photos", nil)
+	c := page.dial(t, "")
+	defer c.CloseNow()
+	readMessage(t, c) // session
+	err = wsjson.Write(ctx, c, bff.Message{Type: "start", Data: "photos"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var input bff.FileInput
+	decodeData(t, readMessage(t, c), &input)
+
+	post := func(query string, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/a/photos/upload/"+input.UploadID+"?"+query, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.AddCookie(page.cookie)
+		req.Header.Set(csrfHeader, page.token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	png := "\x89PNG\r\n\x1a\n"
+	if code := post("file=0&name=cat.png&type=image/png&size=5&offset=0", "meow!"); code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected a file that only claims to be a png to be rejected, got %d", code)
+	}
+	if code := post("file=0&name=cat.png&type=image/png&size=8&offset=0", png); code != http.StatusNoContent {
+		t.Errorf("expected a png to be accepted, got %d", code)
+	}
+	if code := post("file=1&name=dog.png&type=image/png&size=8&offset=0", png); code != http.StatusNoContent {
+		t.Errorf("expected a second png to be accepted, got %d", code)
+	}
+	if code := post("file=2&name=bird.png&type=image/png&size=8&offset=0", png); code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected a file past the most the input takes to be rejected, got %d", code)
+	}
+}
+
+func TestServer_Runs(t *testing.T) {
+	bffInstance := bff.New(bff.WithRunStore(bff.NewMemoryRunStore()))
+	err := bffInstance.RegisterAction("greet", func(ctx context.Context, io *bff.Io) error {
+		name, err := io.Input.Text("What is your name?")
+		if err != nil {
+			return err
+		}
+		io.Display.Heading("Hello, "+name, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := make(chan bff.Message, 1)
+	output := make(chan bff.Message, 2)
+	input <- bff.Message{Type: "input", Data: "gopher"}
+	err = bffInstance.ExecuteAction(context.Background(), "greet", input, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := bffInstance.Runs(context.Background(), bff.RunFilter{})
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected one run, got %v %v", runs, err)
+	}
+	server := NewServer(bffInstance)
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		b, err := io.ReadAll(w.Result().Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w.Result().StatusCode, string(b)
+	}
+	code, body := get("/runs")
+	if code != http.StatusOK || !strings.Contains(body, "/runs/"+runs[0].ID) {
+		t.Errorf("expected the run to be listed, got %d %s", code, body)
+	}
+	code, body = get("/runs/" + runs[0].ID)
+	if code != http.StatusOK || !strings.Contains(body, "What is your name?") || !strings.Contains(body, "gopher") {
+		t.Errorf("expected the prompt and answer, got %d %s", code, body)
+	}
+	if code, _ := get("/runs/nope"); code != http.StatusNotFound {
+		t.Errorf("expected a missing run to be not found, got %d", code)
+	}
+}
+
+func TestServer_CSRF(t *testing.T) {
+	ts := httptest.NewServer(NewServer(testBff(t)))
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/a/some-action/ws"
+	page := openPage(t, ts.URL+"/a/some-action", nil)
+
+	dial := func(url string, header http.Header) int {
+		t.Helper()
+		c, resp, err := websocket.Dial(context.Background(), url, &websocket.DialOptions{HTTPHeader: header})
+		if err == nil {
+			_ = c.CloseNow()
+			return http.StatusSwitchingProtocols
+		}
+		if resp == nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+	cookie := http.Header{"Cookie": {page.cookie.String()}}
+	if code := dial(wsURL, cookie); code != http.StatusForbidden {
+		t.Errorf("expected a socket without a token to be refused, got %d", code)
+	}
+	if code := dial(wsURL+"?csrf="+page.token, nil); code != http.StatusForbidden {
+		t.Errorf("expected a socket without the cookie to be refused, got %d", code)
+	}
+	if code := dial(wsURL+"?csrf=nope", cookie); code != http.StatusForbidden {
+		t.Errorf("expected a socket with the wrong token to be refused, got %d", code)
+	}
+	crossSite := http.Header{"Cookie": {page.cookie.String()}, "Origin": {"https://evil.example"}}
+	if code := dial(wsURL+"?csrf="+page.token, crossSite); code != http.StatusForbidden {
+		t.Errorf("expected a socket from another origin to be refused, got %d", code)
+	}
+	if code := dial(wsURL+"?csrf="+page.token, cookie); code != http.StatusSwitchingProtocols {
+		t.Errorf("expected the page to open its socket, got %d", code)
+	}
+
+	allowed := httptest.NewServer(NewServer(testBff(t), AllowedOrigins("evil.example")))
+	defer allowed.Close()
+	page = openPage(t, allowed.URL+"/a/some-action", nil)
+	crossSite = http.Header{"Cookie": {page.cookie.String()}, "Origin": {"https://evil.example"}}
+	if code := dial("ws"+strings.TrimPrefix(allowed.URL, "http")+"/a/some-action/ws?csrf="+page.token, crossSite); code != http.StatusSwitchingProtocols {
+		t.Errorf("expected an allowed origin to open a socket, got %d", code)
+	}
+}
+
+// page is an action page loaded the way a browser would, with the csrf token it was given
+type page struct {
+	url    string
+	header http.Header
+	cookie *http.Cookie
+	token  string
+}
+
+var csrfMeta = regexp.MustCompile(`<meta name="bff-csrf" content="([^"]+)"`)
+
+func openPage(t *testing.T, url string, header http.Header) page {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := csrfMeta.FindSubmatch(body)
+	if match == nil {
+		t.Fatalf("expected a csrf token in the page, got %d %s", resp.StatusCode, body)
+	}
+	p := page{url: url, header: req.Header, token: string(match[1])}
+	for _, c := range resp.Cookies() {
+		if c.Name == csrfCookie {
+			p.cookie = c
+		}
+	}
+	if p.cookie == nil || p.cookie.Value != p.token {
+		t.Fatalf("expected the csrf cookie to match the page, got %+v", resp.Cookies())
+	}
+	return p
+}
+
+// dial opens the socket of the page, query is added to the url I.E to resume a session
+func (p page) dial(t *testing.T, query string) *websocket.Conn {
+	t.Helper()
+	header := p.header.Clone()
+	header.Add("Cookie", p.cookie.String())
+	url := "ws" + strings.TrimPrefix(p.url, "http") + "/ws?csrf=" + p.token
+	if query != "" {
+		url += "&" + query
+	}
+	c, _, err := websocket.Dial(context.Background(), url, &websocket.DialOptions{HTTPHeader: header})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func readMessage(t *testing.T, c *websocket.Conn) bff.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var m bff.Message
+	err := wsjson.Read(ctx, c, &m)
+	if err != nil {
+		t.Fatal("reading message", err)
+	}
+	return m
+}
+
+// decodeData round trips the loosely typed message data in to v
+func decodeData(t *testing.T, m bff.Message, v any) {
+	t.Helper()
+	b, err := json.Marshal(m.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,12 @@
 package server
 
-import "html/template"
+import (
+	"encoding/json"
+	"html/template"
+	"time"
+
+	"github.com/ebuckley/bff/pkg/bff"
+)
 
 var index = template.Must(template.New("index").Parse(`
 <!DOCTYPE html>
@@ -11,6 +17,8 @@ var index = template.Must(template.New("index").Parse(`
 </head>
 <body class="bg-gray-100 p-4">
  <h1 class="text-3xl font-bold mb-4">{{.Heading}}</h1>
+ {{if .User}}<p class="mb-2 text-gray-600">Signed in as {{.User.Name}}</p>{{end}}
+ <p class="mb-4"><a class="text-blue-600" href="{{.Prefix}}/runs">Past runs</a></p>
  <div class="grid grid-cols-1 md:grid-cols-2 lg:grid-cols-3 gap-4">
  {{$prefix := .Prefix}}
    {{range .Actions}}
@@ -22,3 +30,113 @@ var index = template.Must(template.New("index").Parse(`
 </body>
 </html>
 `))
+
+var runsFuncs = template.FuncMap{
+	"describe": describeStep,
+	"answer":   formatAnswer,
+	"time": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+	"duration": func(r *bff.Run) string {
+		if r.Finished.IsZero() {
+			return ""
+		}
+		return r.Finished.Sub(r.Started).Round(time.Millisecond).String()
+	},
+}
+
+var runsPage = template.Must(template.New("runs").Funcs(runsFuncs).Parse(`
+<!DOCTYPE html>
+<html>
+<head>
+ <title>Runs</title>
+ <link href="https://cdn.jsdelivr.net/npm/tailwindcss@2.2.19/dist/tailwind.min.css" rel="stylesheet">
+</head>
+<body class="bg-gray-100 p-4">
+ <h1 class="text-3xl font-bold mb-4">Runs{{if .Action}} of {{.Action}}{{end}}</h1>
+ <p class="mb-4"><a class="text-blue-600" href="{{.Prefix}}/">Back to actions</a></p>
+ {{$prefix := .Prefix}}
+ <table class="table-auto bg-white w-full">
+  <thead>
+   <tr class="text-left"><th class="p-2">Started</th><th class="p-2">Action</th><th class="p-2">User</th><th class="p-2">Took</th><th class="p-2">Outcome</th></tr>
+  </thead>
+  <tbody>
+  {{range .Runs}}
+   <tr class="border-t">
+    <td class="p-2"><a class="text-blue-600" href="{{$prefix}}/runs/{{.ID}}">{{time .Started}}</a></td>
+    <td class="p-2"><a class="text-blue-600" href="{{$prefix}}/runs?action={{.Action}}">{{.Action}}</a></td>
+    <td class="p-2">{{.User}}</td>
+    <td class="p-2">{{duration .}}</td>
+    <td class="p-2">{{if .Error}}<span class="text-red-600">{{.Error}}</span>{{else if .Finished.IsZero}}not finished{{else}}done{{end}}</td>
+   </tr>
+  {{else}}
+   <tr><td class="p-2" colspan="5">No runs recorded</td></tr>
+  {{end}}
+  </tbody>
+ </table>
+</body>
+</html>
+`))
+
+var runPage = template.Must(template.New("run").Funcs(runsFuncs).Parse(`
+<!DOCTYPE html>
+<html>
+<head>
+ <title>Run of {{.Run.Action}}</title>
+ <link href="https://cdn.jsdelivr.net/npm/tailwindcss@2.2.19/dist/tailwind.min.css" rel="stylesheet">
+</head>
+<body class="bg-gray-100 p-4">
+ {{$run := .Run}}
+ <h1 class="text-3xl font-bold mb-4">Run of {{$run.Action}}</h1>
+ <p class="mb-4"><a class="text-blue-600" href="{{.Prefix}}/runs">Back to runs</a></p>
+ <table class="table-auto bg-white mb-4">
+  <tr><td class="font-bold p-2">ID</td><td class="p-2">{{$run.ID}}</td></tr>
+  <tr><td class="font-bold p-2">User</td><td class="p-2">{{$run.User}}</td></tr>
+  <tr><td class="font-bold p-2">Started</td><td class="p-2">{{time $run.Started}}</td></tr>
+  <tr><td class="font-bold p-2">Finished</td><td class="p-2">{{if $run.Finished.IsZero}}not finished{{else}}{{time $run.Finished}}{{end}}</td></tr>
+  {{if $run.Error}}<tr><td class="font-bold p-2">Error</td><td class="p-2 text-red-600">{{$run.Error}}</td></tr>{{end}}
+  {{if $run.Result}}<tr><td class="font-bold p-2">Result</td><td class="p-2">{{answer $run.Result}}</td></tr>{{end}}
+ </table>
+ <table class="table-auto bg-white w-full">
+  <thead>
+   <tr class="text-left"><th class="p-2">At</th><th class="p-2">Step</th><th class="p-2">Shown</th><th class="p-2">Answer</th></tr>
+  </thead>
+  <tbody>
+  {{range $run.Steps}}
+   <tr class="border-t">
+    <td class="p-2">{{time .At}}</td>
+    <td class="p-2">{{.Kind}}</td>
+    <td class="p-2">{{describe .}}</td>
+    <td class="p-2">{{if .Prompt}}{{answer .Answer}}{{end}}</td>
+   </tr>
+  {{end}}
+  </tbody>
+ </table>
+</body>
+</html>
+`))
+
+// describeStep picks out what the user saw from a step, I.E the label of an input or the text of a heading
+func describeStep(step bff.RunStep) string {
+	var fields map[string]any
+	if json.Unmarshal(step.Element, &fields) != nil {
+		return ""
+	}
+	for _, key := range []string{"label", "message", "text", "content", "url"} {
+		if s, ok := fields[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func formatAnswer(answer any) string {
+	if s, ok := answer.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(answer)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
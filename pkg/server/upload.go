@@ -0,0 +1,64 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/ebuckley/bff/pkg/bff"
+)
+
+// maxChunkSize is the most a single upload request can send, bigger files are sent in chunks
+const maxChunkSize = 8 << 20
+
+// handleUpload receives a chunk of a file for a file input, the chunk is described by the query string I.E
+// ?file=0&name=cat.png&type=image/png&size=1234&offset=0
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if !checkCSRF(r, r.Header.Get(csrfHeader)) {
+		http.Error(w, "missing or bad csrf token", http.StatusForbidden)
+		return
+	}
+	q := r.URL.Query()
+	file, err := strconv.Atoi(q.Get("file"))
+	if err != nil {
+		http.Error(w, "expected file to be a number", http.StatusBadRequest)
+		return
+	}
+	size, err := strconv.ParseInt(q.Get("size"), 10, 64)
+	if err != nil {
+		http.Error(w, "expected size to be a number", http.StatusBadRequest)
+		return
+	}
+	offset, err := strconv.ParseInt(q.Get("offset"), 10, 64)
+	if err != nil {
+		http.Error(w, "expected offset to be a number", http.StatusBadRequest)
+		return
+	}
+	chunk := bff.UploadChunk{
+		File:   file,
+		Name:   q.Get("name"),
+		Type:   q.Get("type"),
+		Size:   size,
+		Offset: offset,
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxChunkSize)
+	err = s.BFF.Upload(r.Context(), r.PathValue("id"), chunk, body)
+	var tooBig *http.MaxBytesError
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, bff.ErrUploadNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, bff.ErrUploadTooLarge), errors.As(err, &tooBig):
+		http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+	case errors.Is(err, bff.ErrUploadNotAccepted):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, bff.ErrUploadOutOfOrder):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		slog.Error("failed to upload file", "err", err)
+		http.Error(w, "failed to upload file", http.StatusInternalServerError)
+	}
+}